@@ -0,0 +1,318 @@
+package gsweb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gsdocker/gserrors"
+	"github.com/gsdocker/gslogger"
+)
+
+// AccessLogFormat the access log line format
+type AccessLogFormat int
+
+// Access log formats
+const (
+	CommonLogFormat   AccessLogFormat = iota // NCSA common log format
+	CombinedLogFormat                        // NCSA combined log format, common format with referer and user agent
+	JSONLogFormat                            // one json object per line
+)
+
+// AccessLogConfig the access log chain node config
+type AccessLogConfig struct {
+	Format       AccessLogFormat     // log line format, default CommonLogFormat
+	Output       io.Writer           // log output, e.g. RotateFile, default writes by gslogger
+	SampleRate   float64             // the ratio of logged requests in (0,1], default 1, 5xx responses are always logged
+	SkipPrefixes []string            // skipped uri prefixes, e.g. health checks and static assets
+	Filter       func(*Context) bool // skip the request if returns false
+}
+
+// AccessLog the chain node writing one log line per request after the
+// response completed
+type AccessLog struct {
+	gslogger.Log                 // Mixin log APIs
+	config       AccessLogConfig // access log config
+	mutex        sync.Mutex      // output guard
+}
+
+// NewAccessLog create access log chain node
+func NewAccessLog(config AccessLogConfig) *AccessLog {
+
+	if config.SampleRate <= 0 || config.SampleRate > 1 {
+		config.SampleRate = 1
+	}
+
+	return &AccessLog{
+		Log:    gslogger.Get("access"),
+		config: config,
+	}
+}
+
+// HandleUnknown implement Unknown interface
+func (accessLog *AccessLog) HandleUnknown(context *Context) error {
+
+	if accessLog.skip(context) {
+		return context.Forward()
+	}
+
+	context.OnFinish(func() {
+
+		if context.Status() < 500 && accessLog.config.SampleRate < 1 && rand.Float64() >= accessLog.config.SampleRate {
+			return
+		}
+
+		accessLog.write(context)
+	})
+
+	return context.Forward()
+}
+
+func (accessLog *AccessLog) skip(context *Context) bool {
+
+	for _, prefix := range accessLog.config.SkipPrefixes {
+		if strings.HasPrefix(context.RequestURI(), prefix) {
+			return true
+		}
+	}
+
+	return accessLog.config.Filter != nil && !accessLog.config.Filter(context)
+}
+
+func (accessLog *AccessLog) write(context *Context) {
+
+	var line string
+
+	switch accessLog.config.Format {
+	case CombinedLogFormat:
+		line = combinedLogLine(context)
+	case JSONLogFormat:
+		line = jsonLogLine(context)
+	default:
+		line = commonLogLine(context)
+	}
+
+	if accessLog.config.Output == nil {
+		accessLog.I("%s", line)
+		return
+	}
+
+	accessLog.mutex.Lock()
+	defer accessLog.mutex.Unlock()
+
+	if _, err := io.WriteString(accessLog.config.Output, line+"\n"); err != nil {
+		accessLog.E("write access log error : %s", err)
+	}
+}
+
+// clientIP get the client ip from request remote address
+func clientIP(context *Context) string {
+
+	host, _, err := net.SplitHostPort(context.Request().RemoteAddr)
+
+	if err != nil {
+		return context.Request().RemoteAddr
+	}
+
+	return host
+}
+
+func logField(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+func commonLogLine(context *Context) string {
+
+	request := context.Request()
+
+	user := "-"
+
+	if request.URL.User != nil {
+		user = logField(request.URL.User.Username())
+	} else if name, _, ok := request.BasicAuth(); ok {
+		user = logField(name)
+	}
+
+	return fmt.Sprintf("%s - %s [%s] %q %d %d",
+		clientIP(context),
+		user,
+		context.Started().Format("02/Jan/2006:15:04:05 -0700"),
+		request.Method+" "+request.RequestURI+" "+request.Proto,
+		context.Status(),
+		context.Size(),
+	)
+}
+
+func combinedLogLine(context *Context) string {
+	return fmt.Sprintf("%s %q %q",
+		commonLogLine(context),
+		logField(context.Request().Referer()),
+		logField(context.Request().UserAgent()),
+	)
+}
+
+// accessLogEntry the json access log line
+type accessLogEntry struct {
+	Time      string  `json:"time"`                // request start time, RFC3339
+	RequestID string  `json:"requestId"`           // request id
+	Client    string  `json:"client"`              // client ip
+	Method    string  `json:"method"`              // request method
+	URI       string  `json:"uri"`                 // request uri
+	Proto     string  `json:"proto"`               // request protocol
+	Status    int     `json:"status"`              // response status code
+	Size      int64   `json:"size"`                // response body bytes
+	Latency   float64 `json:"latency_ms"`          // request latency in milliseconds
+	Referer   string  `json:"referer,omitempty"`   // request referer
+	UserAgent string  `json:"userAgent,omitempty"` // request user agent
+}
+
+func jsonLogLine(context *Context) string {
+
+	request := context.Request()
+
+	content, _ := json.Marshal(&accessLogEntry{
+		Time:      context.Started().Format(time.RFC3339Nano),
+		RequestID: context.RequestID(),
+		Client:    clientIP(context),
+		Method:    request.Method,
+		URI:       request.RequestURI,
+		Proto:     request.Proto,
+		Status:    context.Status(),
+		Size:      context.Size(),
+		Latency:   float64(time.Since(context.Started())) / float64(time.Millisecond),
+		Referer:   request.Referer(),
+		UserAgent: request.UserAgent(),
+	})
+
+	return string(content)
+}
+
+// RotateFile the log file writer rotating by size, the rotated files are
+// renamed as path.1, path.2 ... path.N, the oldest file is removed
+type RotateFile struct {
+	mutex      sync.Mutex // file guard
+	path       string     // log file path
+	maxSize    int64      // rotate when file size exceeds maxSize bytes
+	maxBackups int        // max rotated files kept
+	file       *os.File   // current log file
+	size       int64      // current log file size
+}
+
+// NewRotateFile open log file rotating when exceeds maxSize bytes, keeping at
+// most maxBackups rotated files
+func NewRotateFile(path string, maxSize int64, maxBackups int) (*RotateFile, error) {
+
+	rotateFile := &RotateFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+
+	if err := rotateFile.open(); err != nil {
+		return nil, err
+	}
+
+	return rotateFile, nil
+}
+
+func (rotateFile *RotateFile) open() error {
+
+	file, err := os.OpenFile(rotateFile.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		return gserrors.Newf(err, "open log file %s error", rotateFile.path)
+	}
+
+	info, err := file.Stat()
+
+	if err != nil {
+		file.Close()
+		return gserrors.Newf(err, "stat log file %s error", rotateFile.path)
+	}
+
+	rotateFile.file = file
+	rotateFile.size = info.Size()
+
+	return nil
+}
+
+// rotate rename current file as backup and open new one, the current file is
+// reopened if rename failed so logging continues
+func (rotateFile *RotateFile) rotate() error {
+
+	rotateFile.file.Close()
+
+	rotateFile.file = nil
+
+	var err error
+
+	if rotateFile.maxBackups > 0 {
+		os.Remove(fmt.Sprintf("%s.%d", rotateFile.path, rotateFile.maxBackups))
+
+		for i := rotateFile.maxBackups - 1; i > 0; i-- {
+			os.Rename(fmt.Sprintf("%s.%d", rotateFile.path, i), fmt.Sprintf("%s.%d", rotateFile.path, i+1))
+		}
+
+		err = os.Rename(rotateFile.path, rotateFile.path+".1")
+	} else {
+		err = os.Remove(rotateFile.path)
+	}
+
+	if openErr := rotateFile.open(); openErr != nil {
+		return openErr
+	}
+
+	if err != nil {
+		return gserrors.Newf(err, "rotate log file %s error", rotateFile.path)
+	}
+
+	return nil
+}
+
+// Write implement io.Writer
+func (rotateFile *RotateFile) Write(buff []byte) (int, error) {
+	rotateFile.mutex.Lock()
+	defer rotateFile.mutex.Unlock()
+
+	if rotateFile.file == nil {
+		return 0, gserrors.Newf(nil, "log file %s closed", rotateFile.path)
+	}
+
+	if rotateFile.maxSize > 0 && rotateFile.size > 0 && rotateFile.size+int64(len(buff)) > rotateFile.maxSize {
+		if err := rotateFile.rotate(); err != nil && rotateFile.file == nil {
+			return 0, err
+		}
+	}
+
+	n, err := rotateFile.file.Write(buff)
+
+	rotateFile.size += int64(n)
+
+	return n, err
+}
+
+// Close implement io.Closer
+func (rotateFile *RotateFile) Close() error {
+	rotateFile.mutex.Lock()
+	defer rotateFile.mutex.Unlock()
+
+	if rotateFile.file == nil {
+		return nil
+	}
+
+	err := rotateFile.file.Close()
+
+	rotateFile.file = nil
+
+	return err
+}
@@ -0,0 +1,215 @@
+package gsweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type accessLogTestHandler struct{}
+
+func (accessLogTestHandler) HandleGet(context *Context) error {
+
+	if context.RequestURI() == "/fail" {
+		panic("fail")
+	}
+
+	return context.Text(200, "hello")
+}
+
+// serveAccessLog serve requests by router logging with config, returns the
+// logged lines
+func serveAccessLog(config AccessLogConfig, paths ...string) []string {
+
+	var output bytes.Buffer
+
+	config.Output = &output
+
+	router := newRouter()
+	router.ChainHandle("access", NewAccessLog(config))
+
+	uri := NewURIHandler()
+	uri.Handle("/a", accessLogTestHandler{})
+	uri.Handle("/fail", accessLogTestHandler{})
+	uri.Handle("/health", accessLogTestHandler{})
+	router.ChainHandle("uri", uri)
+
+	for _, path := range paths {
+
+		request := httptest.NewRequest("GET", path, nil)
+		request.SetBasicAuth("neo", "secret")
+		request.Header.Set("Referer", "http://example.com/")
+		request.Header.Set("User-Agent", "test-agent")
+
+		router.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	return strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+}
+
+func TestAccessLogFormat(t *testing.T) {
+
+	const common = `192\.0\.2\.1 - neo \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [-+]\d{4}\] "GET /a\?x=1 HTTP/1\.1" 200 5`
+
+	tests := []struct {
+		name   string
+		format AccessLogFormat
+		line   string
+	}{
+		{"common", CommonLogFormat, "^" + common + "$"},
+		{"combined", CombinedLogFormat, "^" + common + ` "http://example\.com/" "test-agent"$`},
+	}
+
+	for _, test := range tests {
+
+		lines := serveAccessLog(AccessLogConfig{Format: test.format}, "/a?x=1")
+
+		if len(lines) != 1 || !regexp.MustCompile(test.line).MatchString(lines[0]) {
+			t.Errorf("%s log got %q", test.name, lines)
+		}
+	}
+
+	lines := serveAccessLog(AccessLogConfig{Format: JSONLogFormat}, "/a?x=1")
+
+	var entry accessLogEntry
+
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("decode json log %q error : %s", lines[0], err)
+	}
+
+	if entry.RequestID == "" || entry.Time == "" || entry.Latency < 0 {
+		t.Errorf("json log missing fields : %+v", entry)
+	}
+
+	entry.RequestID, entry.Time, entry.Latency = "", "", 0
+
+	expect := accessLogEntry{
+		Client:    "192.0.2.1",
+		Method:    "GET",
+		URI:       "/a?x=1",
+		Proto:     "HTTP/1.1",
+		Status:    200,
+		Size:      5,
+		Referer:   "http://example.com/",
+		UserAgent: "test-agent",
+	}
+
+	if entry != expect {
+		t.Errorf("json log got %+v, expect %+v", entry, expect)
+	}
+}
+
+func TestAccessLogFilter(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		config AccessLogConfig
+		paths  []string
+		logged string // the logged uris and status
+	}{
+		{
+			"skip prefixes",
+			AccessLogConfig{SkipPrefixes: []string{"/health"}},
+			[]string{"/a", "/health", "/health/db", "/a"},
+			"/a 200,/a 200",
+		},
+		{
+			"filter",
+			AccessLogConfig{Filter: func(context *Context) bool { return context.Request().URL.Query().Get("log") != "no" }},
+			[]string{"/a?log=no", "/a?log=yes"},
+			"/a?log=yes 200",
+		},
+		{
+			"sampling keeps server errors",
+			AccessLogConfig{SampleRate: 1e-12},
+			[]string{"/a", "/a", "/fail", "/a"},
+			"/fail 500",
+		},
+		{
+			"invalid sample rate logs all",
+			AccessLogConfig{SampleRate: 2},
+			[]string{"/a", "/missing"},
+			"/a 200,/missing 404",
+		},
+	}
+
+	for _, test := range tests {
+
+		test.config.Format = JSONLogFormat
+
+		var logged []string
+
+		for _, line := range serveAccessLog(test.config, test.paths...) {
+
+			if line == "" {
+				continue
+			}
+
+			var entry accessLogEntry
+
+			if err := json.Unmarshal([]byte(line), &entry); err != nil {
+				t.Fatalf("%s decode json log %q error : %s", test.name, line, err)
+			}
+
+			logged = append(logged, entry.URI+" "+strconv.Itoa(entry.Status))
+		}
+
+		if got := strings.Join(logged, ","); got != test.logged {
+			t.Errorf("%s logged %q, expect %q", test.name, got, test.logged)
+		}
+	}
+}
+
+func TestRotateFile(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "access.log")
+
+	file, err := NewRotateFile(path, 10, 2)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := map[string]string{
+		path:        "fourth\n",
+		path + ".1": "third\n",
+		path + ".2": "second\n",
+	}
+
+	for name, content := range expect {
+
+		got, err := os.ReadFile(name)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(got) != content {
+			t.Errorf("%s got %q, expect %q", name, got, content)
+		}
+	}
+
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("backups beyond max kept : %v", err)
+	}
+
+	if _, err := file.Write([]byte("closed\n")); err == nil {
+		t.Error("write closed file must fail")
+	}
+}
@@ -0,0 +1,487 @@
+package gsweb
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gsdocker/gserrors"
+	"github.com/gsdocker/gslogger"
+)
+
+// authFileCheckPeriod the period checking credential files modification
+const authFileCheckPeriod = 5 * time.Second
+
+// Principal the authenticated identity
+type Principal struct {
+	Subject  string                 // the authenticated user or client
+	Provider string                 // the provider name authenticated the request
+	Claims   map[string]interface{} // the token claims, nil for providers without claims
+}
+
+// AuthProvider the authentication provider
+type AuthProvider interface {
+	// Name get the provider name
+	Name() string
+	// Authenticate authenticate request, returns nil principal and nil error if
+	// the request carries no credentials of the provider
+	Authenticate(context *Context) (*Principal, error)
+	// Challenge get the WWW-Authenticate challenge, err is the Authenticate
+	// error, nil if the request carried no credentials
+	Challenge(err error) string
+}
+
+// AuthConfig the authentication chain node config
+type AuthConfig struct {
+	Required       bool     // reject unauthenticated requests by 401 Unauthorized
+	PublicPrefixes []string // uri prefixes not requiring authentication when Required
+}
+
+// Auth the chain node authenticating request by the first provider which
+// found credentials, the principal is stored on Context
+type Auth struct {
+	gslogger.Log                // Mixin log APIs
+	config       AuthConfig     // auth config
+	providers    []AuthProvider // providers tried in order
+}
+
+// NewAuth create authentication chain node
+func NewAuth(config AuthConfig, providers ...AuthProvider) *Auth {
+	return &Auth{
+		Log:       gslogger.Get("auth"),
+		config:    config,
+		providers: providers,
+	}
+}
+
+// HandleUnknown implement Unknown interface
+func (auth *Auth) HandleUnknown(context *Context) error {
+
+	for _, provider := range auth.providers {
+
+		principal, err := provider.Authenticate(context)
+
+		if err != nil {
+			auth.D("%s %s %s authenticate error : %s", context.RequestMethod(), context.RequestURI(), provider.Name(), err)
+
+			return auth.unauthorized(context, provider, err)
+		}
+
+		if principal != nil {
+			principal.Provider = provider.Name()
+			context.principal = principal
+			return context.Forward()
+		}
+	}
+
+	if !auth.config.Required || context.RequestMethod() == "OPTIONS" {
+		return context.Forward()
+	}
+
+	for _, prefix := range auth.config.PublicPrefixes {
+		if matchPrefix(context.RequestURI(), prefix) {
+			return context.Forward()
+		}
+	}
+
+	return auth.unauthorized(context, nil, nil)
+}
+
+// unauthorized reject request by 401 with the challenges of providers, the
+// failed provider's challenge carries the error
+func (auth *Auth) unauthorized(context *Context, failed AuthProvider, err error) error {
+
+	header := context.Response().Header()
+
+	for _, provider := range auth.providers {
+
+		var challenge string
+
+		if provider == failed {
+			challenge = provider.Challenge(err)
+		} else {
+			challenge = provider.Challenge(nil)
+		}
+
+		if challenge != "" {
+			header.Add("WWW-Authenticate", challenge)
+		}
+	}
+
+	// 401 response must carry at least one challenge
+	if header.Get("WWW-Authenticate") == "" {
+		header.Set("WWW-Authenticate", "Bearer")
+	}
+
+	httpError := NewHTTPError(http.StatusUnauthorized, err, "")
+
+	return context.Failed(httpError, "%s %s unauthorized", context.RequestMethod(), context.RequestURI())
+}
+
+// Principal get the authenticated principal, returns nil if not authenticated
+func (context *Context) Principal() *Principal {
+	return context.principal
+}
+
+// BasicAuth the HTTP Basic authentication provider verifying credentials
+// against htpasswd file, the file is reloaded when modified.
+//
+// Supported password formats are {SHA}, $apr1$ (Apache MD5) and plain text
+// if enabled, bcrypt ($2y$) requires golang.org/x/crypto so such users are
+// rejected and reported when loading the file, other formats are rejected
+type BasicAuth struct {
+	gslogger.Log                   // Mixin log APIs
+	path         string            // htpasswd file path
+	realm        string            // authentication realm
+	plaintext    bool              // accept plain text passwords
+	mutex        sync.Mutex        // users guard
+	users        map[string]string // password hashes indexed by user
+	modTime      time.Time         // loaded file modification time
+	lastCheck    time.Time         // last modification check time
+}
+
+// NewBasicAuth create HTTP Basic authentication provider by htpasswd file
+func NewBasicAuth(path string, realm string) (*BasicAuth, error) {
+
+	basic := &BasicAuth{
+		Log:   gslogger.Get("auth"),
+		path:  path,
+		realm: realm,
+	}
+
+	if err := basic.load(); err != nil {
+		return nil, err
+	}
+
+	return basic, nil
+}
+
+// EnablePlaintext set flag, true accept the entries not in a supported hash
+// format as plain text passwords, otherwise such users are rejected
+func (basic *BasicAuth) EnablePlaintext(flag bool) *BasicAuth {
+	basic.mutex.Lock()
+	defer basic.mutex.Unlock()
+
+	basic.plaintext = flag
+
+	return basic
+}
+
+// load load htpasswd file, must be called with mutex held or before shared
+func (basic *BasicAuth) load() error {
+
+	info, err := os.Stat(basic.path)
+
+	if err != nil {
+		return gserrors.Newf(err, "stat htpasswd file %s error", basic.path)
+	}
+
+	content, err := os.ReadFile(basic.path)
+
+	if err != nil {
+		return gserrors.Newf(err, "read htpasswd file %s error", basic.path)
+	}
+
+	users := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+
+	for scanner.Scan() {
+
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		colon := strings.IndexByte(line, ':')
+
+		if colon == -1 {
+			continue
+		}
+
+		user, hash := line[:colon], line[colon+1:]
+
+		if strings.HasPrefix(hash, "$2") {
+			basic.W("htpasswd user %s uses unsupported bcrypt hash, the user can't login", user)
+		}
+
+		users[user] = hash
+	}
+
+	basic.users = users
+	basic.modTime = info.ModTime()
+	basic.lastCheck = time.Now()
+
+	return nil
+}
+
+// lookup get user's password hash, reload file if modified
+func (basic *BasicAuth) lookup(user string) (string, bool) {
+	basic.mutex.Lock()
+	defer basic.mutex.Unlock()
+
+	if time.Since(basic.lastCheck) > authFileCheckPeriod {
+
+		basic.lastCheck = time.Now()
+
+		if info, err := os.Stat(basic.path); err == nil && !info.ModTime().Equal(basic.modTime) {
+			basic.I("htpasswd file %s changed, reload", basic.path)
+
+			if err := basic.load(); err != nil {
+				basic.E("reload htpasswd file error : %s", err)
+			}
+		}
+	}
+
+	hash, ok := basic.users[user]
+
+	return hash, ok
+}
+
+// plaintextEnabled check if plain text passwords are accepted
+func (basic *BasicAuth) plaintextEnabled() bool {
+	basic.mutex.Lock()
+	defer basic.mutex.Unlock()
+
+	return basic.plaintext
+}
+
+// Name implement AuthProvider
+func (basic *BasicAuth) Name() string {
+	return "basic"
+}
+
+// Authenticate implement AuthProvider
+func (basic *BasicAuth) Authenticate(context *Context) (*Principal, error) {
+
+	user, password, ok := context.Request().BasicAuth()
+
+	if !ok {
+		return nil, nil
+	}
+
+	hash, ok := basic.lookup(user)
+
+	if !ok {
+		return nil, gserrors.Newf(nil, "unknown user %s", user)
+	}
+
+	verified, supported := verifyHtpasswd(password, hash, basic.plaintextEnabled())
+
+	if !supported {
+		basic.W("htpasswd user %s uses unsupported hash format, rejected", user)
+	}
+
+	if !verified {
+		return nil, gserrors.Newf(nil, "invalid password of user %s", user)
+	}
+
+	return &Principal{Subject: user}, nil
+}
+
+// Challenge implement AuthProvider
+func (basic *BasicAuth) Challenge(err error) string {
+	return `Basic realm="` + strings.Replace(basic.realm, `"`, `'`, -1) + `", charset="UTF-8"`
+}
+
+// verifyHtpasswd verify password by htpasswd hash, the unrecognized hash is
+// compared as plain text only if plaintext is true, the second return value
+// indicate if the hash format is supported
+func verifyHtpasswd(password string, hash string, plaintext bool) (bool, bool) {
+
+	var computed string
+
+	switch {
+	case strings.HasPrefix(hash, "{SHA}"):
+		sum := sha1.Sum([]byte(password))
+		computed = "{SHA}" + base64.StdEncoding.EncodeToString(sum[:])
+
+	case strings.HasPrefix(hash, "$apr1$"):
+		salt := strings.TrimPrefix(hash, "$apr1$")
+
+		if i := strings.IndexByte(salt, '$'); i != -1 {
+			salt = salt[:i]
+		}
+
+		computed = apr1(password, salt)
+
+	case plaintext && !strings.HasPrefix(hash, "$"):
+		computed = password
+
+	default:
+		// bcrypt, DES crypt and other variants are not supported, never
+		// compare them as plain text or the hash itself becomes the password
+		return false, false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(hash)) == 1, true
+}
+
+// apr1Alphabet the crypt base64 alphabet
+const apr1Alphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// apr1 compute Apache MD5 crypt hash
+func apr1(password string, salt string) string {
+
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+
+	const magic = "$apr1$"
+
+	alternate := md5.Sum([]byte(password + salt + password))
+
+	digest := md5.New()
+
+	digest.Write([]byte(password + magic + salt))
+
+	for i := len(password); i > 0; i -= 16 {
+		if i > 16 {
+			digest.Write(alternate[:])
+		} else {
+			digest.Write(alternate[:i])
+		}
+	}
+
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 == 1 {
+			digest.Write([]byte{0})
+		} else {
+			digest.Write([]byte{password[0]})
+		}
+	}
+
+	final := digest.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+
+		round := md5.New()
+
+		if i&1 == 1 {
+			round.Write([]byte(password))
+		} else {
+			round.Write(final)
+		}
+
+		if i%3 != 0 {
+			round.Write([]byte(salt))
+		}
+
+		if i%7 != 0 {
+			round.Write([]byte(password))
+		}
+
+		if i&1 == 1 {
+			round.Write(final)
+		} else {
+			round.Write([]byte(password))
+		}
+
+		final = round.Sum(nil)
+	}
+
+	var result strings.Builder
+
+	result.WriteString(magic + salt + "$")
+
+	encode := func(a, b, c byte, n int) {
+		value := uint(a)<<16 | uint(b)<<8 | uint(c)
+
+		for ; n > 0; n-- {
+			result.WriteByte(apr1Alphabet[value&0x3f])
+			value >>= 6
+		}
+	}
+
+	encode(final[0], final[6], final[12], 4)
+	encode(final[1], final[7], final[13], 4)
+	encode(final[2], final[8], final[14], 4)
+	encode(final[3], final[9], final[15], 4)
+	encode(final[4], final[10], final[5], 4)
+	encode(0, 0, final[11], 2)
+
+	return result.String()
+}
+
+// APIKeyAuth the static API key authentication provider, the key is read
+// from the key header or Authorization: Bearer header
+type APIKeyAuth struct {
+	header string                       // the key header, e.g. X-API-Key
+	keys   map[[sha256.Size]byte]string // subjects indexed by key hash
+}
+
+// NewAPIKeyAuth create API key authentication provider, keys map the API key
+// to its subject, empty header defaults to X-API-Key
+func NewAPIKeyAuth(header string, keys map[string]string) *APIKeyAuth {
+
+	if header == "" {
+		header = "X-API-Key"
+	}
+
+	// index by hash so lookup time doesn't leak key prefixes
+	hashed := make(map[[sha256.Size]byte]string, len(keys))
+
+	for key, subject := range keys {
+		hashed[sha256.Sum256([]byte(key))] = subject
+	}
+
+	return &APIKeyAuth{header: header, keys: hashed}
+}
+
+// Name implement AuthProvider
+func (apiKey *APIKeyAuth) Name() string {
+	return "apikey"
+}
+
+// Authenticate implement AuthProvider
+func (apiKey *APIKeyAuth) Authenticate(context *Context) (*Principal, error) {
+
+	if key := context.Request().Header.Get(apiKey.header); key != "" {
+
+		subject, ok := apiKey.keys[sha256.Sum256([]byte(key))]
+
+		if !ok {
+			return nil, gserrors.Newf(nil, "invalid api key")
+		}
+
+		return &Principal{Subject: subject}, nil
+	}
+
+	// unknown bearer tokens are left to the following providers, e.g. JWT
+	if token, ok := bearerToken(context.Request()); ok {
+		if subject, ok := apiKey.keys[sha256.Sum256([]byte(token))]; ok {
+			return &Principal{Subject: subject}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Challenge implement AuthProvider
+func (apiKey *APIKeyAuth) Challenge(err error) string {
+	return ""
+}
+
+// bearerToken get the token of Authorization: Bearer header
+func bearerToken(request *http.Request) (string, bool) {
+
+	authorization := request.Header.Get("Authorization")
+
+	if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "Bearer ") {
+		return "", false
+	}
+
+	token := strings.TrimSpace(authorization[7:])
+
+	return token, token != ""
+}
@@ -0,0 +1,338 @@
+package gsweb
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestVerifyHtpasswd(t *testing.T) {
+
+	tests := []struct {
+		password  string
+		hash      string
+		plaintext bool
+		verified  bool
+		supported bool
+	}{
+		// hashes generated by openssl passwd -apr1 and htpasswd -s
+		{"myPassword", "$apr1$r31.....$HqJZimcKQFAMYayBlzkrA/", false, true, true},
+		{"a-much-longer-password-than-16", "$apr1$abcdefgh$I2rVGfTw/B5HUqH5PL9k21", false, true, true},
+		{"wrong", "$apr1$abcdefgh$I2rVGfTw/B5HUqH5PL9k21", false, false, true},
+		{"secret", "{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=", false, true, true},
+		{"wrong", "{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=", false, false, true},
+		{"plain", "plain", true, true, true},
+		{"plain", "plain", false, false, false},
+		// DES crypt hash must not become the password itself
+		{"rqXexS6ZhobKA", "rqXexS6ZhobKA", false, false, false},
+		{"x", "$2y$05$abcdefghijklmnopqrstuu", true, false, false},
+	}
+
+	for _, test := range tests {
+
+		verified, supported := verifyHtpasswd(test.password, test.hash, test.plaintext)
+
+		if verified != test.verified || supported != test.supported {
+			t.Errorf("verify %s by %s got %v %v, expect %v %v",
+				test.password, test.hash, verified, supported, test.verified, test.supported)
+		}
+	}
+}
+
+func TestNumericClaim(t *testing.T) {
+
+	tests := []struct {
+		value string
+		valid bool
+		unix  int64
+	}{
+		{"1700000000", true, 1700000000},
+		{"1700000000.5", true, 1700000000},
+		{"1e300", false, 0},
+		{"9223372036854775807", false, 0},
+		{"-1", false, 0},
+	}
+
+	for _, test := range tests {
+
+		claims := map[string]interface{}{"exp": json.Number(test.value)}
+
+		value, ok, err := numericClaim(claims, "exp")
+
+		if (err == nil) != test.valid || (test.valid && (!ok || value.Unix() != test.unix)) {
+			t.Errorf("numeric claim %s got %v %v %v", test.value, value, ok, err)
+		}
+	}
+
+	if _, ok, err := numericClaim(map[string]interface{}{}, "exp"); ok || err != nil {
+		t.Errorf("absent claim got %v %v", ok, err)
+	}
+}
+
+func encodeSegment(buff []byte) string {
+	return base64.RawURLEncoding.EncodeToString(buff)
+}
+
+// signJWT sign claims by HS256, RS256, PS256 or ES256 key
+func signJWT(t *testing.T, alg string, kid string, claims map[string]interface{}, key interface{}) string {
+
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+
+	input := encodeSegment(header) + "." + encodeSegment(payload)
+
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(input))
+	hashed := digest.Sum(nil)
+
+	var signature []byte
+	var err error
+
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(crypto.SHA256.New, key)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		if alg == "PS256" {
+			signature, err = rsa.SignPSS(rand.Reader, key, crypto.SHA256, hashed, nil)
+		} else {
+			signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed)
+		}
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, hashed)
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return input + "." + encodeSegment(signature)
+}
+
+func writeJWKS(t *testing.T, path string, keys ...map[string]string) {
+
+	content, _ := json.Marshal(map[string]interface{}{"keys": keys})
+
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"n":   encodeSegment(key.N.Bytes()),
+		"e":   encodeSegment(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+type authTestHandler struct{}
+
+func (authTestHandler) HandleGet(context *Context) error {
+
+	principal := context.Principal()
+
+	if principal == nil {
+		return context.Text(200, "anonymous")
+	}
+
+	return context.Text(200, "%s:%s", principal.Provider, principal.Subject)
+}
+
+func TestAuth(t *testing.T) {
+
+	dir := t.TempDir()
+
+	htpasswd := filepath.Join(dir, "htpasswd")
+
+	content := "alice:{SHA}5en6G6MezRroT3XKqkdPOmY/BfQ=\nbob:$apr1$r31.....$HqJZimcKQFAMYayBlzkrA/\ncarol:rqXexS6ZhobKA\n"
+
+	if err := os.WriteFile(htpasswd, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	basic, err := NewBasicAuth(htpasswd, "test")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	jwks := filepath.Join(dir, "jwks.json")
+
+	writeJWKS(t, jwks, rsaJWK("rsa-1", rsaKey), map[string]string{
+		"kty": "EC",
+		"kid": "ec-1",
+		"crv": "P-256",
+		"x":   encodeSegment(ecKey.X.FillBytes(make([]byte, 32))),
+		"y":   encodeSegment(ecKey.Y.FillBytes(make([]byte, 32))),
+	})
+
+	secret := []byte("hmac-secret")
+
+	jwt, err := NewJWTAuth(JWTConfig{
+		Realm:    "api",
+		Issuer:   "issuer",
+		Audience: "audience",
+		HMACKeys: map[string][]byte{"hmac-1": secret},
+		JWKSFile: jwks,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := newRouter()
+	router.ChainHandle("auth", NewAuth(
+		AuthConfig{Required: true, PublicPrefixes: []string{"/public"}},
+		basic, NewAPIKeyAuth("", map[string]string{"key-1": "svc"}), jwt))
+
+	uri := NewURIHandler()
+	uri.Handle("/private", authTestHandler{})
+	uri.Handle("/public", authTestHandler{})
+	router.ChainHandle("uri", uri)
+
+	do := func(path string, header string, value string) (int, string, []string) {
+
+		request := httptest.NewRequest("GET", path, nil)
+
+		if header != "" {
+			request.Header.Set(header, value)
+		}
+
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, request)
+
+		return recorder.Code, recorder.Body.String(), recorder.Header().Values("WWW-Authenticate")
+	}
+
+	basicAuth := func(user, password string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+	}
+
+	now := time.Now().Unix()
+
+	claims := func(modify func(claims map[string]interface{})) map[string]interface{} {
+
+		claims := map[string]interface{}{"sub": "user-1", "iss": "issuer", "aud": []string{"other", "audience"}, "exp": now + 60}
+
+		if modify != nil {
+			modify(claims)
+		}
+
+		return claims
+	}
+
+	bearer := func(alg string, kid string, claims map[string]interface{}, key interface{}) string {
+		return "Bearer " + signJWT(t, alg, kid, claims, key)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		value  string
+		code   int
+		body   string
+	}{
+		{"public", "/public", "", "", 200, "anonymous"},
+		{"sha", "/private", "Authorization", basicAuth("alice", "secret"), 200, "basic:alice"},
+		{"apr1", "/private", "Authorization", basicAuth("bob", "myPassword"), 200, "basic:bob"},
+		{"wrong password", "/private", "Authorization", basicAuth("alice", "wrong"), 401, ""},
+		{"unknown user", "/private", "Authorization", basicAuth("dave", "secret"), 401, ""},
+		{"des crypt as password", "/private", "Authorization", basicAuth("carol", "rqXexS6ZhobKA"), 401, ""},
+		{"api key", "/private", "X-API-Key", "key-1", 200, "apikey:svc"},
+		{"api key bearer", "/private", "Authorization", "Bearer key-1", 200, "apikey:svc"},
+		{"invalid api key", "/private", "X-API-Key", "key-2", 401, ""},
+		{"hs256", "/private", "Authorization", bearer("HS256", "hmac-1", claims(nil), secret), 200, "jwt:user-1"},
+		{"rs256", "/private", "Authorization", bearer("RS256", "rsa-1", claims(nil), rsaKey), 200, "jwt:user-1"},
+		{"ps256", "/private", "Authorization", bearer("PS256", "rsa-1", claims(nil), rsaKey), 200, "jwt:user-1"},
+		{"es256", "/private", "Authorization", bearer("ES256", "ec-1", claims(nil), ecKey), 200, "jwt:user-1"},
+		{"unknown kid", "/private", "Authorization", bearer("HS256", "hmac-2", claims(nil), secret), 401, ""},
+		{"algorithm confusion", "/private", "Authorization", bearer("HS256", "rsa-1", claims(nil), rsaKey.N.Bytes()), 401, ""},
+		{"expired", "/private", "Authorization", bearer("HS256", "hmac-1", claims(func(c map[string]interface{}) {
+			c["exp"] = now - 10
+		}), secret), 401, ""},
+		{"huge exp", "/private", "Authorization", bearer("HS256", "hmac-1", claims(func(c map[string]interface{}) {
+			c["exp"] = 1e19
+		}), secret), 401, ""},
+		{"not before", "/private", "Authorization", bearer("HS256", "hmac-1", claims(func(c map[string]interface{}) {
+			c["nbf"] = now + 60
+		}), secret), 401, ""},
+		{"issuer", "/private", "Authorization", bearer("HS256", "hmac-1", claims(func(c map[string]interface{}) {
+			c["iss"] = "other"
+		}), secret), 401, ""},
+		{"audience", "/private", "Authorization", bearer("HS256", "hmac-1", claims(func(c map[string]interface{}) {
+			c["aud"] = "other"
+		}), secret), 401, ""},
+		{"missing subject", "/private", "Authorization", bearer("HS256", "hmac-1", claims(func(c map[string]interface{}) {
+			delete(c, "sub")
+		}), secret), 401, ""},
+		{"empty subject", "/private", "Authorization", bearer("HS256", "hmac-1", claims(func(c map[string]interface{}) {
+			c["sub"] = ""
+		}), secret), 401, ""},
+		{"non string subject", "/private", "Authorization", bearer("HS256", "hmac-1", claims(func(c map[string]interface{}) {
+			c["sub"] = 42
+		}), secret), 401, ""},
+		{"malformed", "/private", "Authorization", "Bearer a.b", 401, ""},
+		{"anonymous", "/private", "", "", 401, ""},
+	}
+
+	for _, test := range tests {
+
+		code, body, challenges := do(test.path, test.header, test.value)
+
+		if code != test.code || (test.body != "" && body != test.body) {
+			t.Errorf("%s got %d %s, expect %d %s", test.name, code, body, test.code, test.body)
+		}
+
+		if code == 401 && len(challenges) != 2 {
+			t.Errorf("%s got challenges %v", test.name, challenges)
+		}
+	}
+
+	// rotate the JWKS file, the removed key must be rejected
+	rotated, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	writeJWKS(t, jwks, rsaJWK("rsa-2", rotated))
+
+	future := time.Now().Add(time.Minute)
+
+	os.Chtimes(jwks, future, future)
+
+	jwt.mutex.Lock()
+	jwt.lastCheck = time.Time{}
+	jwt.mutex.Unlock()
+
+	if code, body, _ := do("/private", "Authorization", bearer("RS256", "rsa-2", claims(nil), rotated)); code != 200 || body != "jwt:user-1" {
+		t.Errorf("rotated key got %d %s", code, body)
+	}
+
+	if code, _, _ := do("/private", "Authorization", bearer("RS256", "rsa-1", claims(nil), rsaKey)); code != 401 {
+		t.Errorf("removed key got %d", code)
+	}
+
+	if code, _, _ := do("/private", "Authorization", bearer("HS256", "hmac-1", claims(nil), secret)); code != 200 {
+		t.Errorf("hmac key after rotation got %d", code)
+	}
+}
@@ -0,0 +1,305 @@
+package gsweb
+
+import (
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gsdocker/gsconfig"
+)
+
+var (
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Bind decode the request body into v by the request Content-Type, then
+// validate v by the struct field's validate tag.
+//
+// Supported Content-Type are application/json(+json), application/xml,
+// text/xml(+xml), application/x-www-form-urlencoded and multipart/form-data,
+// the form values are bound to struct fields by the form tag, multipart files
+// are bound to *multipart.FileHeader or []*multipart.FileHeader fields.
+//
+// On error the request is marked failed by context.Failed with *HTTPError
+// responds 400 Bad Request or 415 Unsupported Media Type, handlers should
+// return the error directly
+func (context *Context) Bind(v interface{}) error {
+
+	if err := context.decodeBody(v); err != nil {
+		return context.Failed(err, "%s %s decode request body error", context.RequestMethod(), context.RequestURI())
+	}
+
+	if err := Validate(v); err != nil {
+		return context.Failed(err, "%s %s validate request error", context.RequestMethod(), context.RequestURI())
+	}
+
+	return nil
+}
+
+// decodeBody decode the request body into v by the request Content-Type
+func (context *Context) decodeBody(v interface{}) error {
+
+	request := context.Request()
+
+	contentType := request.Header.Get("Content-Type")
+
+	if contentType == "" && (request.Body == nil || request.Body == http.NoBody || request.ContentLength == 0) {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	if err != nil {
+		return NewHTTPError(http.StatusUnsupportedMediaType, err, "invalid Content-Type %s", contentType)
+	}
+
+	maxBytes := int64(gsconfig.Int("max_body_bytes", 10<<20))
+
+	request.Body = http.MaxBytesReader(context.responseWriter, request.Body, maxBytes)
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		err = json.NewDecoder(request.Body).Decode(v)
+
+	case mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml"):
+		err = xml.NewDecoder(request.Body).Decode(v)
+
+	case mediaType == "application/x-www-form-urlencoded":
+		if err = request.ParseForm(); err == nil {
+			err = bindValues(v, request.PostForm, "form")
+		}
+
+	case mediaType == "multipart/form-data":
+		if err = request.ParseMultipartForm(maxBytes); err == nil {
+			err = bindValues(v, request.MultipartForm.Value, "form")
+
+			if err == nil {
+				err = bindFiles(v, request.MultipartForm.File)
+			}
+		}
+
+	default:
+		return NewHTTPError(http.StatusUnsupportedMediaType, nil, "unsupported Content-Type %s", mediaType)
+	}
+
+	if err != nil {
+		if _, ok := err.(*HTTPError); ok {
+			return err
+		}
+
+		return NewHTTPError(http.StatusBadRequest, err, "invalid request body")
+	}
+
+	return nil
+}
+
+// structValue get the struct value pointed by v
+func structValue(v interface{}) (reflect.Value, bool) {
+	value := reflect.ValueOf(v)
+
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return value, false
+	}
+
+	return value.Elem(), true
+}
+
+// fieldName get the field's name by tag, returns false if the field is skipped
+func fieldName(field reflect.StructField, tag string) (string, bool) {
+
+	if field.PkgPath != "" && !field.Anonymous {
+		return "", false
+	}
+
+	name := strings.Split(field.Tag.Get(tag), ",")[0]
+
+	if name == "-" {
+		return "", false
+	}
+
+	// only form fields fallback to the field name
+	if name == "" && tag == "form" {
+		name = field.Name
+	}
+
+	return name, name != ""
+}
+
+// walkFields call f with every bindable field of struct value, embedded
+// structs are flattened
+func walkFields(value reflect.Value, tag string, f func(name string, field reflect.Value)) {
+
+	valueType := value.Type()
+
+	for i := 0; i < valueType.NumField(); i++ {
+
+		field := valueType.Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get(tag) == "" {
+			walkFields(value.Field(i), tag, f)
+			continue
+		}
+
+		if name, ok := fieldName(field, tag); ok {
+			f(name, value.Field(i))
+		}
+	}
+}
+
+// bindValues bind string values into struct fields named by tag
+func bindValues(v interface{}, values map[string][]string, tag string) error {
+
+	value, ok := structValue(v)
+
+	if !ok {
+		return nil
+	}
+
+	var fields []FieldError
+
+	walkFields(value, tag, func(name string, field reflect.Value) {
+
+		texts, ok := values[name]
+
+		if !ok || len(texts) == 0 {
+			return
+		}
+
+		if err := setField(field, texts); err != nil {
+			fields = append(fields, FieldError{Field: name, Reason: err.Error()})
+		}
+	})
+
+	if len(fields) > 0 {
+		return &HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: "invalid request parameters",
+			Fields:  fields,
+		}
+	}
+
+	return nil
+}
+
+// bindFiles bind multipart files into struct fields named by form tag
+func bindFiles(v interface{}, files map[string][]*multipart.FileHeader) error {
+
+	value, ok := structValue(v)
+
+	if !ok {
+		return nil
+	}
+
+	walkFields(value, "form", func(name string, field reflect.Value) {
+
+		headers, ok := files[name]
+
+		if !ok || len(headers) == 0 {
+			return
+		}
+
+		if field.Type() == fileHeaderType {
+			field.Set(reflect.ValueOf(headers[0]))
+		} else if field.Kind() == reflect.Slice && field.Type().Elem() == fileHeaderType {
+			field.Set(reflect.ValueOf(headers))
+		}
+	})
+
+	return nil
+}
+
+// setField set field by string values
+func setField(field reflect.Value, texts []string) error {
+
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+
+		slice := reflect.MakeSlice(field.Type(), len(texts), len(texts))
+
+		for i, text := range texts {
+			if err := setValue(slice.Index(i), text); err != nil {
+				return err
+			}
+		}
+
+		field.Set(slice)
+
+		return nil
+	}
+
+	return setValue(field, texts[0])
+}
+
+// setValue set value by string
+func setValue(value reflect.Value, text string) error {
+
+	if value.Kind() == reflect.Ptr {
+
+		elem := reflect.New(value.Type().Elem())
+
+		if err := setValue(elem.Elem(), text); err != nil {
+			return err
+		}
+
+		value.Set(elem)
+
+		return nil
+	}
+
+	if value.CanAddr() && value.Addr().Type().Implements(textUnmarshalerType) {
+		return value.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text))
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(text)
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+
+		if err != nil {
+			return fmt.Errorf("expect bool value")
+		}
+
+		value.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 10, value.Type().Bits())
+
+		if err != nil {
+			return fmt.Errorf("expect integer value")
+		}
+
+		value.SetInt(i)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(text, 10, value.Type().Bits())
+
+		if err != nil {
+			return fmt.Errorf("expect unsigned integer value")
+		}
+
+		value.SetUint(u)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, value.Type().Bits())
+
+		if err != nil {
+			return fmt.Errorf("expect number value")
+		}
+
+		value.SetFloat(f)
+
+	default:
+		return fmt.Errorf("unsupported field type %s", value.Type())
+	}
+
+	return nil
+}
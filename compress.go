@@ -0,0 +1,378 @@
+package gsweb
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gsdocker/gserrors"
+	"github.com/gsdocker/gslogger"
+)
+
+// EncoderFactory create the compress writer of content encoding writing into w
+type EncoderFactory func(w io.Writer) (io.WriteCloser, error)
+
+// flusher the compress writer supporting flush pending data, e.g. gzip.Writer
+type flusher interface {
+	Flush() error
+}
+
+// CompressionConfig the compression chain node config
+type CompressionConfig struct {
+	Level     int      // gzip and deflate compression level, default gzip.DefaultCompression
+	MinSize   int      // bodies smaller than MinSize bytes are not compressed, default 1024
+	SkipTypes []string // skipped content types appended to the defaults, type/* matches the whole type
+}
+
+// defaultSkipTypes the already compressed content types
+var defaultSkipTypes = []string{
+	"image/*", "video/*", "audio/*", "font/woff", "font/woff2",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-bzip2",
+	"application/x-7z-compressed", "application/x-rar-compressed", "application/x-xz",
+	"application/zstd", "application/pdf", "application/wasm", "application/octet-stream",
+}
+
+// encoder the registered content encoding
+type encoder struct {
+	name    string         // content encoding name
+	factory EncoderFactory // compress writer factory
+}
+
+// Compression the chain node compressing responses by the content encoding
+// negotiated by Accept-Encoding header, the response is streamed once the
+// body exceeds the minimum size
+type Compression struct {
+	gslogger.Log                   // Mixin log APIs
+	config       CompressionConfig // compression config
+	encoders     []*encoder        // encoders ordered by preference
+}
+
+// NewCompression create compression chain node with gzip and deflate encoders
+func NewCompression(config CompressionConfig) *Compression {
+
+	if config.Level == 0 {
+		config.Level = gzip.DefaultCompression
+	}
+
+	if config.MinSize == 0 {
+		config.MinSize = 1024
+	}
+
+	config.SkipTypes = append(append([]string(nil), defaultSkipTypes...), config.SkipTypes...)
+
+	compression := &Compression{
+		Log:    gslogger.Get("compress"),
+		config: config,
+	}
+
+	level := config.Level
+
+	compression.Register("deflate", func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, level)
+	})
+
+	compression.Register("gzip", func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, level)
+	})
+
+	return compression
+}
+
+// Register register content encoding, e.g. br backed by a brotli library, the
+// encodings registered later are preferred when client accepts them equally
+func (compression *Compression) Register(name string, factory EncoderFactory) *Compression {
+
+	name = strings.ToLower(name)
+
+	for i, encoder := range compression.encoders {
+		if encoder.name == name {
+			compression.encoders = append(compression.encoders[:i], compression.encoders[i+1:]...)
+			break
+		}
+	}
+
+	compression.encoders = append([]*encoder{{name: name, factory: factory}}, compression.encoders...)
+
+	return compression
+}
+
+// negotiateEncoding select the content encoding in offers by Accept-Encoding
+// header, offers are ordered by server preference, returns empty string if
+// none acceptable
+func negotiateEncoding(header string, offers []string) string {
+
+	if strings.TrimSpace(header) == "" {
+		return ""
+	}
+
+	specs := parseQualityList(header)
+
+	best, bestQ := "", 0.0
+
+	for _, offer := range offers {
+
+		q, found, wildcard := 0.0, false, -1.0
+
+		for _, spec := range specs {
+			if spec.value == offer || (offer == "gzip" && spec.value == "x-gzip") {
+				q, found = spec.q, true
+				break
+			}
+
+			if spec.value == "*" && wildcard < 0 {
+				wildcard = spec.q
+			}
+		}
+
+		if !found && wildcard > 0 {
+			q = wildcard
+		}
+
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+
+	return best
+}
+
+// skipType check if content type is already compressed
+func (compression *Compression) skipType(contentType string) bool {
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	}
+
+	// svg is text even under image/*
+	if mediaType == "image/svg+xml" {
+		return false
+	}
+
+	for _, skip := range compression.config.SkipTypes {
+		if skip == mediaType || (strings.HasSuffix(skip, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(skip, "*"))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HandleUnknown implement Unknown interface
+func (compression *Compression) HandleUnknown(context *Context) error {
+
+	request := context.Request()
+
+	context.Response().Header().Add("Vary", "Accept-Encoding")
+
+	if request.Header.Get("Upgrade") != "" || request.Method == "HEAD" {
+		return context.Forward()
+	}
+
+	offers := make([]string, len(compression.encoders))
+
+	for i, encoder := range compression.encoders {
+		offers[i] = encoder.name
+	}
+
+	name := negotiateEncoding(request.Header.Get("Accept-Encoding"), offers)
+
+	if name == "" {
+		return context.Forward()
+	}
+
+	writer := &compressWriter{
+		ResponseWriter: context.responseWriter.ResponseWriter,
+		compression:    compression,
+		encoding:       name,
+		factory:        compression.encoders[indexEncoder(offers, name)].factory,
+		status:         http.StatusOK,
+	}
+
+	context.responseWriter.ResponseWriter = writer
+
+	context.OnFinish(func() {
+		if err := writer.Close(); err != nil {
+			compression.W("%s %s close %s encoder error : %s", request.Method, context.RequestURI(), name, err)
+		}
+	})
+
+	return context.Forward()
+}
+
+func indexEncoder(offers []string, name string) int {
+	for i, offer := range offers {
+		if offer == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// compressWriter the response writer compressing body, the header is held
+// until the body exceeds the minimum size, or the response is flushed or closed
+type compressWriter struct {
+	http.ResponseWriter                // Mixin underlying response writer
+	compression         *Compression   // compression node
+	encoding            string         // negotiated content encoding
+	factory             EncoderFactory // compress writer factory
+	status              int            // held status code
+	buff                []byte         // held body
+	decided             bool           // indicate if header committed
+	encoder             io.WriteCloser // compress writer, nil if not compressed
+}
+
+// WriteHeader implement http.ResponseWriter
+func (w *compressWriter) WriteHeader(code int) {
+
+	if w.decided {
+		return
+	}
+
+	// informational response are sent directly
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	w.status = code
+
+	// the responses without body and partial contents are never compressed
+	if code == http.StatusNoContent || code == http.StatusNotModified || code == http.StatusSwitchingProtocols ||
+		code == http.StatusPartialContent {
+		w.commit(false)
+	}
+}
+
+// Write implement http.ResponseWriter
+func (w *compressWriter) Write(buff []byte) (int, error) {
+
+	if !w.decided {
+
+		w.buff = append(w.buff, buff...)
+
+		if len(w.buff) < w.compression.config.MinSize {
+			return len(buff), nil
+		}
+
+		if err := w.commit(true); err != nil {
+			return 0, err
+		}
+
+		return len(buff), nil
+	}
+
+	if w.encoder != nil {
+		return w.encoder.Write(buff)
+	}
+
+	return w.ResponseWriter.Write(buff)
+}
+
+// commit decide if compress the response, then write header and held body
+func (w *compressWriter) commit(allowed bool) error {
+
+	w.decided = true
+
+	header := w.ResponseWriter.Header()
+
+	if header.Get("Content-Type") == "" && len(w.buff) > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buff))
+	}
+
+	if allowed && header.Get("Content-Encoding") == "" && header.Get("Content-Range") == "" &&
+		!w.compression.skipType(header.Get("Content-Type")) {
+
+		encoder, err := w.factory(w.ResponseWriter)
+
+		if err != nil {
+			w.compression.E("create %s encoder error : %s", w.encoding, err)
+		} else {
+			w.encoder = encoder
+
+			header.Set("Content-Encoding", w.encoding)
+			header.Del("Content-Length")
+			header.Del("Accept-Ranges")
+
+			// the compressed representation is not byte identical
+			if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				header.Set("ETag", "W/"+etag)
+			}
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buff := w.buff
+
+	w.buff = nil
+
+	if len(buff) == 0 {
+		return nil
+	}
+
+	var err error
+
+	if w.encoder != nil {
+		_, err = w.encoder.Write(buff)
+	} else {
+		_, err = w.ResponseWriter.Write(buff)
+	}
+
+	return err
+}
+
+// Flush implement http.Flusher, the held body is sent compressed only if it
+// exceeds the minimum size
+func (w *compressWriter) Flush() {
+
+	if !w.decided {
+		w.commit(len(w.buff) >= w.compression.config.MinSize)
+	}
+
+	if encoder, ok := w.encoder.(flusher); ok {
+		encoder.Flush()
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close commit held body and close encoder
+func (w *compressWriter) Close() error {
+
+	if !w.decided {
+		if err := w.commit(len(w.buff) >= w.compression.config.MinSize); err != nil {
+			return err
+		}
+	}
+
+	if w.encoder != nil {
+		return w.encoder.Close()
+	}
+
+	return nil
+}
+
+// Hijack implement http.Hijacker
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		w.decided = true
+		return hijacker.Hijack()
+	}
+
+	return nil, nil, gserrors.Newf(nil, "underlying response writer not implement http.Hijacker")
+}
+
+// Unwrap support http.ResponseController
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
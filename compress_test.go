@@ -0,0 +1,273 @@
+package gsweb
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+
+	offers := []string{"br", "gzip", "deflate"}
+
+	tests := []struct {
+		header string
+		expect string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"x-gzip", "gzip"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0.5, deflate", "deflate"},
+		{"br, gzip", "br"},
+		{"*", "br"},
+		{"*;q=0.5, gzip;q=0", "br"},
+		{"gzip;q=0", ""},
+		{"identity", ""},
+		{"GZIP", "gzip"},
+	}
+
+	for _, test := range tests {
+		if got := negotiateEncoding(test.header, offers); got != test.expect {
+			t.Errorf("Accept-Encoding %q got %q, expect %q", test.header, got, test.expect)
+		}
+	}
+}
+
+type compressTestHandler struct{}
+
+func (compressTestHandler) HandleGet(context *Context) error {
+
+	switch context.RequestURI() {
+	case "/small":
+		return context.Text(200, "small")
+	case "/png":
+		return context.write(200, "image/png", bytes.Repeat([]byte{0x89}, 2048))
+	case "/etag":
+		context.Response().Header().Set("ETag", `"v1"`)
+	case "/empty":
+		context.Response().WriteHeader(204)
+		return context.Success()
+	}
+
+	return context.Text(200, "%s", strings.Repeat("compress me ", 200))
+}
+
+func TestCompression(t *testing.T) {
+
+	large := strings.Repeat("compress me ", 200)
+
+	router := newRouter()
+	router.ChainHandle("compress", NewCompression(CompressionConfig{}))
+
+	uri := NewURIHandler()
+
+	for _, path := range []string{"/large", "/small", "/png", "/etag", "/empty"} {
+		uri.Handle(path, compressTestHandler{})
+	}
+
+	router.ChainHandle("uri", uri)
+
+	decoders := map[string]func(r io.Reader) (io.Reader, error){
+		"": func(r io.Reader) (io.Reader, error) { return r, nil },
+		"gzip": func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+		"deflate": func(r io.Reader) (io.Reader, error) {
+			return flate.NewReader(r), nil
+		},
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		accept   string
+		encoding string
+		body     string
+		etag     string
+	}{
+		{"gzip", "GET", "/large", "gzip, deflate;q=0.5", "gzip", large, ""},
+		{"deflate", "GET", "/large", "deflate", "deflate", large, ""},
+		{"not accepted", "GET", "/large", "br", "", large, ""},
+		{"no accept encoding", "GET", "/large", "", "", large, ""},
+		{"small body", "GET", "/small", "gzip", "", "small", ""},
+		{"compressed type", "GET", "/png", "gzip", "", strings.Repeat("\x89", 2048), ""},
+		{"weak etag", "GET", "/etag", "gzip", "gzip", large, `W/"v1"`},
+		{"identity etag", "GET", "/etag", "", "", large, `"v1"`},
+		{"no content", "GET", "/empty", "gzip", "", "", ""},
+		{"head", "HEAD", "/large", "gzip", "", "", ""},
+	}
+
+	for _, test := range tests {
+
+		request := httptest.NewRequest(test.method, test.path, nil)
+
+		if test.accept != "" {
+			request.Header.Set("Accept-Encoding", test.accept)
+		}
+
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, request)
+
+		header := recorder.Header()
+
+		if got := header.Get("Content-Encoding"); got != test.encoding {
+			t.Errorf("%s encoding got %q, expect %q", test.name, got, test.encoding)
+			continue
+		}
+
+		if got := header.Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("%s vary got %q", test.name, got)
+		}
+
+		if test.encoding != "" && header.Get("Content-Length") != "" {
+			t.Errorf("%s compressed response keeps content length %s", test.name, header.Get("Content-Length"))
+		}
+
+		if got := header.Get("ETag"); got != test.etag {
+			t.Errorf("%s etag got %q, expect %q", test.name, got, test.etag)
+		}
+
+		reader, err := decoders[test.encoding](recorder.Body)
+
+		if err != nil {
+			t.Fatalf("%s create decoder error : %s", test.name, err)
+		}
+
+		body, err := io.ReadAll(reader)
+
+		if err != nil {
+			t.Fatalf("%s decode body error : %s", test.name, err)
+		}
+
+		if string(body) != test.body {
+			t.Errorf("%s body got %d bytes, expect %d bytes", test.name, len(body), len(test.body))
+		}
+	}
+}
+
+func TestCompressionRegister(t *testing.T) {
+
+	router := newRouter()
+	router.ChainHandle("compress", NewCompression(CompressionConfig{MinSize: 1}).Register("identity-test", func(w io.Writer) (io.WriteCloser, error) {
+		return nopWriteCloser{w}, nil
+	}))
+
+	uri := NewURIHandler()
+	uri.Handle("/small", compressTestHandler{})
+	router.ChainHandle("uri", uri)
+
+	request := httptest.NewRequest("GET", "/small", nil)
+	request.Header.Set("Accept-Encoding", "gzip, identity-test")
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+
+	if got := recorder.Header().Get("Content-Encoding"); got != "identity-test" {
+		t.Errorf("registered encoding not preferred, got %q", got)
+	}
+
+	if recorder.Body.String() != "small" {
+		t.Errorf("body got %q", recorder.Body.String())
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+func TestFileHandlerPrecompressed(t *testing.T) {
+
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"app.js":    "console.log(1)",
+		"app.js.br": "brotli bytes",
+		"plain.js":  "plain",
+	}
+
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fileHandler := NewFileHandler()
+
+	registerPath, err := fileHandler.RegisterPath("/", dir)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registerPath.EnablePrecompressed(true)
+
+	router := newRouter()
+	router.ChainHandle("file", fileHandler)
+
+	tests := []struct {
+		name     string
+		path     string
+		accept   string
+		encoding string
+		body     string
+	}{
+		{"precompressed sibling", "/app.js", "gzip, br", "br", "brotli bytes"},
+		{"sibling not accepted", "/app.js", "gzip", "", "console.log(1)"},
+		{"no sibling", "/plain.js", "br", "", "plain"},
+	}
+
+	for _, test := range tests {
+
+		request := httptest.NewRequest("GET", test.path, nil)
+		request.Header.Set("Accept-Encoding", test.accept)
+
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, request)
+
+		if got := recorder.Header().Get("Content-Encoding"); got != test.encoding {
+			t.Errorf("%s encoding got %q, expect %q", test.name, got, test.encoding)
+		}
+
+		if got := recorder.Header().Get("Vary"); got != "Accept-Encoding" && test.encoding != "" {
+			t.Errorf("%s vary got %q", test.name, got)
+		}
+
+		if got := recorder.Header().Get("Content-Type"); !strings.Contains(got, "javascript") {
+			t.Errorf("%s content type got %q", test.name, got)
+		}
+
+		if recorder.Body.String() != test.body {
+			t.Errorf("%s body got %q, expect %q", test.name, recorder.Body.String(), test.body)
+		}
+	}
+
+	// the register root never serves siblings, escaping paths are cleaned into the root
+	for _, relative := range []string{"", "/", "../app.js.br/../app.js", "../../etc/passwd"} {
+
+		request := httptest.NewRequest("GET", "/", nil)
+		request.Header.Set("Accept-Encoding", "br")
+
+		context := newContext(router, request, httptest.NewRecorder())
+
+		served := fileHandler.servePrecompressed(context, registerPath, relative)
+
+		if expect := relative == "../app.js.br/../app.js"; served != expect {
+			t.Errorf("relative path %q served %v, expect %v", relative, served, expect)
+		}
+	}
+}
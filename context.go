@@ -1,7 +1,11 @@
 package gsweb
 
 import (
+	gocontext "context"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/gsdocker/gserrors"
 	"github.com/gsdocker/gslogger"
@@ -10,10 +14,71 @@ import (
 // Context the request handler context
 type Context struct {
 	gslogger.Log
-	Router         *Router             // router belongs
-	responseWriter http.ResponseWriter // response writer
-	request        *http.Request       // request
-	forwardCursor  int                 // The forward chain cursor
+	Router         *Router                // router belongs
+	responseWriter *responseWriter        // response writer
+	request        *http.Request          // request
+	handleChain    []*Handler             // The handle chain snapshot when request started
+	forwardCursor  int                    // The forward chain cursor
+	params         []routeParam           // The URIHandler captured path parameters
+	failure        error                  // The error passed to the first Failed call
+	succeeded      bool                   // Indicate if handler called Success
+	allowMethods   []string               // The methods allowed by matched uri but not the request method
+	session        *Session               // The session loaded by Sessions chain node
+	valuesMutex    sync.RWMutex           // The values guard
+	values         map[string]interface{} // The per-request values
+	started        time.Time              // The request start time
+	finish         []func()               // The hooks called after the response completed
+	handledBy      string                 // The chain node name which handled the request
+	route          string                 // The URIHandler matched route pattern
+	trace          *requestTrace          // The request trace, nil if tracing disabled
+	requestID      string                 // The request id
+	csrf           *CSRF                  // The CSRF chain node processed the request
+	cspNonce       string                 // The Content-Security-Policy nonce
+	principal      *Principal             // The principal authenticated by Auth chain node
+}
+
+// RequestIDHeader the header carrying request id
+const RequestIDHeader = "X-Request-ID"
+
+// requestLog the log prefixing request id to every line
+type requestLog struct {
+	gslogger.Log        // Mixin log APIs
+	prefix       string // the log line prefix
+}
+
+// V implement gslogger.Log
+func (log *requestLog) V(format string, args ...interface{}) {
+	log.Log.V(log.prefix+format, args...)
+}
+
+// D implement gslogger.Log
+func (log *requestLog) D(format string, args ...interface{}) {
+	log.Log.D(log.prefix+format, args...)
+}
+
+// I implement gslogger.Log
+func (log *requestLog) I(format string, args ...interface{}) {
+	log.Log.I(log.prefix+format, args...)
+}
+
+// W implement gslogger.Log
+func (log *requestLog) W(format string, args ...interface{}) {
+	log.Log.W(log.prefix+format, args...)
+}
+
+// E implement gslogger.Log
+func (log *requestLog) E(format string, args ...interface{}) {
+	log.Log.E(log.prefix+format, args...)
+}
+
+// validRequestID check the incoming request id, only short ids made of
+// alphanumerics and -_.: are accepted to keep logs and headers safe
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	return strings.Trim(id, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_.:") == ""
 }
 
 func newContext(
@@ -21,23 +86,34 @@ func newContext(
 	request *http.Request,
 	response http.ResponseWriter) *Context {
 
+	requestID := request.Header.Get(RequestIDHeader)
+
+	if !validRequestID(requestID) {
+		requestID = randomHex(16)
+	}
+
+	response.Header().Set(RequestIDHeader, requestID)
+
 	return &Context{
-		Log:            router.Log,
+		Log:            &requestLog{Log: router.Log, prefix: "[" + requestID + "] "},
 		Router:         router,
 		request:        request,
-		responseWriter: response,
+		responseWriter: newResponseWriter(response),
+		handleChain:    router.chain(),
 		forwardCursor:  0,
+		started:        time.Now(),
+		requestID:      requestID,
 	}
 }
 
 // Forward forward request to next handler
 func (context *Context) Forward() error {
 
-	for len(context.Router.handleChain) > context.forwardCursor {
+	for len(context.handleChain) > context.forwardCursor {
 		cursor := context.forwardCursor
 		context.forwardCursor++
 
-		handler := context.Router.handleChain[cursor]
+		handler := context.handleChain[cursor]
 
 		method, ok := handler.methods[context.RequestMethod()]
 
@@ -47,10 +123,21 @@ func (context *Context) Forward() error {
 		}
 
 		if ok {
-			err := method(context)
+			var err error
+
+			if context.trace != nil {
+				err = context.trace.call(handler.name, context, method)
+			} else {
+				err = method(context)
+			}
+
+			// the innermost node which completed the request handled it
+			if context.handledBy == "" && (context.succeeded || context.failure != nil || context.Written()) {
+				context.handledBy = handler.name
+			}
 
 			gserrors.Assert(
-				context.forwardCursor == len(context.Router.handleChain),
+				context.forwardCursor == len(context.handleChain),
 				"handler method %s#%s must call context.Success or context.Failed before return",
 				handler.name,
 				context.RequestMethod(),
@@ -65,13 +152,18 @@ func (context *Context) Forward() error {
 
 // Failed break the request handler chain processing and return error
 func (context *Context) Failed(err error, fmt string, args ...interface{}) error {
-	context.forwardCursor = len(context.Router.handleChain) // request handler cotract check codes
+	if context.failure == nil {
+		context.failure = err
+	}
+
+	context.forwardCursor = len(context.handleChain) // request handler cotract check codes
 	return gserrors.Newf(err, fmt, args...)
 }
 
 // Success break the request handler chain processing and return success
 func (context *Context) Success() error {
-	context.forwardCursor = len(context.Router.handleChain) // request handler cotract check codes
+	context.succeeded = true
+	context.forwardCursor = len(context.handleChain) // request handler cotract check codes
 	return nil
 }
 
@@ -85,6 +177,63 @@ func (context *Context) Response() http.ResponseWriter {
 	return context.responseWriter
 }
 
+// Written indicate if the response header has been written
+func (context *Context) Written() bool {
+	return context.responseWriter.written
+}
+
+// BeforeWrite register hook called before writing response header, the hook
+// can still modify response headers, e.g. set cookies
+func (context *Context) BeforeWrite(hook func()) {
+	context.responseWriter.beforeWrite = append(context.responseWriter.beforeWrite, hook)
+}
+
+// Handled indicate if any handler wrote the response or called Success
+func (context *Context) Handled() bool {
+	return context.succeeded || context.Written()
+}
+
+// OnFinish register hook called after the response completed, including the
+// error response rendered by router and the recovered panic, hooks are called
+// in reverse order of registration
+func (context *Context) OnFinish(hook func()) {
+	context.finish = append(context.finish, hook)
+}
+
+// RequestID get the request id accepted from X-Request-ID header or generated,
+// set it on outbound requests to correlate logs across services
+func (context *Context) RequestID() string {
+	return context.requestID
+}
+
+// HandlerName get the name of chain node which handled the request, returns
+// empty string if no chain node handled it
+func (context *Context) HandlerName() string {
+	return context.handledBy
+}
+
+// Route get the route pattern matched by URIHandler, returns empty string if
+// no route matched
+func (context *Context) Route() string {
+	return context.route
+}
+
+// Started get the request start time
+func (context *Context) Started() time.Time {
+	return context.started
+}
+
+// Size get the written response body bytes
+func (context *Context) Size() int64 {
+	return context.responseWriter.size
+}
+
+// Status get the written response status code, returns 0 if the response
+// header has not been written
+func (context *Context) Status() int {
+	return context.responseWriter.status
+}
+
 // RequestMethod get http request's method
 func (context *Context) RequestMethod() string {
 	return context.request.Method
@@ -95,6 +244,90 @@ func (context *Context) RequestURI() string {
 	return context.request.URL.Path
 }
 
+// Param get the path parameter captured by URIHandler's route pattern,
+// returns empty string if the parameter not exists
+func (context *Context) Param(name string) string {
+	value, _ := context.LookupParam(name)
+	return value
+}
+
+// LookupParam get the path parameter captured by URIHandler's route pattern,
+// the second return value indicate if the parameter exists
+func (context *Context) LookupParam(name string) (string, bool) {
+	for _, param := range context.params {
+		if param.name == name {
+			return param.value, true
+		}
+	}
+
+	return "", false
+}
+
+// Params get all path parameters captured by URIHandler's route pattern
+func (context *Context) Params() map[string]string {
+	params := make(map[string]string, len(context.params))
+
+	for _, param := range context.params {
+		params[param.name] = param.value
+	}
+
+	return params
+}
+
+// Set set per-request value, which can be read by the following chain nodes
+func (context *Context) Set(key string, value interface{}) {
+	context.valuesMutex.Lock()
+	defer context.valuesMutex.Unlock()
+
+	if context.values == nil {
+		context.values = make(map[string]interface{})
+	}
+
+	context.values[key] = value
+}
+
+// Get get per-request value, the second return value indicate if the key exists
+func (context *Context) Get(key string) (interface{}, bool) {
+	context.valuesMutex.RLock()
+	defer context.valuesMutex.RUnlock()
+
+	value, ok := context.values[key]
+
+	return value, ok
+}
+
+// Value get typed per-request value, the second return value is false if the
+// key not exists or the value is not type T
+func Value[T any](context *Context, key string) (T, bool) {
+	value, ok := context.Get(key)
+
+	if !ok {
+		var zero T
+		return zero, false
+	}
+
+	typed, ok := value.(T)
+
+	return typed, ok
+}
+
+// Ctx get the request's context.Context, which is canceled when the client
+// connection closed, or the website drain timeout expired during shutdown
+func (context *Context) Ctx() gocontext.Context {
+	return context.request.Context()
+}
+
+// WithTimeout create child context.Context of Ctx canceled after timeout,
+// pass it to downstream calls that should abort when the client goes away
+func (context *Context) WithTimeout(timeout time.Duration) (gocontext.Context, gocontext.CancelFunc) {
+	return gocontext.WithTimeout(context.Ctx(), timeout)
+}
+
+// WithDeadline create child context.Context of Ctx canceled at deadline
+func (context *Context) WithDeadline(deadline time.Time) (gocontext.Context, gocontext.CancelFunc) {
+	return gocontext.WithDeadline(context.Ctx(), deadline)
+}
+
 // Redirect redirect url
 func (context *Context) Redirect(urlStr string, code int) {
 	http.Redirect(context.responseWriter, context.request, urlStr, code)
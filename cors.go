@@ -0,0 +1,179 @@
+package gsweb
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gsdocker/gserrors"
+	"github.com/gsdocker/gslogger"
+)
+
+// CORSConfig the CORS chain node config
+type CORSConfig struct {
+	AllowOrigins     []string      // allowed origins, exact (https://example.com), wildcard (https://*.example.com) or * for any
+	AllowOriginRegex []string      // allowed origin regex patterns, matched against the whole origin
+	AllowMethods     []string      // allowed methods, default GET, HEAD, POST, PUT, PATCH, DELETE
+	AllowHeaders     []string      // allowed request headers, default reflects the preflight requested headers
+	ExposeHeaders    []string      // response headers exposed to scripts
+	AllowCredentials bool          // allow cookies and authorization headers, can't be combined with * origin
+	MaxAge           time.Duration // preflight result cache duration, zero omits the header
+}
+
+// CORS the chain node answering CORS preflight requests and adding CORS
+// headers to the responses of allowed origins
+type CORS struct {
+	gslogger.Log                  // Mixin log APIs
+	config       CORSConfig       // CORS config
+	allowAll     bool             // indicate if any origin is allowed
+	origins      map[string]bool  // exact allowed origins
+	patterns     []*regexp.Regexp // wildcard and regex allowed origins
+	methods      map[string]bool  // allowed methods
+}
+
+// NewCORS create CORS chain node, register it before URIHandler so the
+// preflight requests are answered before route lookup. Allowing credentials
+// for any origin is rejected, it lets every site read the user's responses
+func NewCORS(config CORSConfig) *CORS {
+
+	if len(config.AllowMethods) == 0 {
+		config.AllowMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+	}
+
+	cors := &CORS{
+		Log:     gslogger.Get("cors"),
+		config:  config,
+		origins: make(map[string]bool),
+		methods: make(map[string]bool),
+	}
+
+	for _, origin := range config.AllowOrigins {
+
+		switch {
+		case origin == "*":
+			cors.allowAll = true
+
+		case strings.Contains(origin, "*"):
+			pattern := strings.Replace(regexp.QuoteMeta(strings.ToLower(origin)), `\*`, `[a-z0-9.-]+`, -1)
+			cors.patterns = append(cors.patterns, regexp.MustCompile("^"+pattern+"$"))
+
+		default:
+			cors.origins[strings.ToLower(origin)] = true
+		}
+	}
+
+	gserrors.Assert(
+		!cors.allowAll || !config.AllowCredentials,
+		"CORS AllowOrigins * can't be combined with AllowCredentials, list the trusted origins instead")
+
+	for _, source := range config.AllowOriginRegex {
+		pattern, err := regexp.Compile("^(?:" + source + ")$")
+		gserrors.Assert(err == nil, "invalid CORS origin regex %s : %s", source, err)
+		cors.patterns = append(cors.patterns, pattern)
+	}
+
+	for _, method := range config.AllowMethods {
+		cors.methods[strings.ToUpper(method)] = true
+	}
+
+	return cors
+}
+
+// allowOrigin check if origin is allowed
+func (cors *CORS) allowOrigin(origin string) bool {
+
+	if cors.allowAll {
+		return true
+	}
+
+	origin = strings.ToLower(origin)
+
+	if cors.origins[origin] {
+		return true
+	}
+
+	for _, pattern := range cors.patterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HandleUnknown implement Unknown interface
+func (cors *CORS) HandleUnknown(context *Context) error {
+
+	request := context.Request()
+
+	header := context.Response().Header()
+
+	origin := request.Header.Get("Origin")
+
+	// the response varies by origin unless every origin gets *
+	if !cors.allowAll {
+		header.Add("Vary", "Origin")
+	}
+
+	if origin == "" {
+		return context.Forward()
+	}
+
+	if !cors.allowOrigin(origin) {
+		cors.D("%s %s origin %s not allowed", request.Method, context.RequestURI(), origin)
+		return context.Forward()
+	}
+
+	requestMethod := request.Header.Get("Access-Control-Request-Method")
+
+	preflight := request.Method == "OPTIONS" && requestMethod != ""
+
+	if preflight {
+		header.Add("Vary", "Access-Control-Request-Method")
+		header.Add("Vary", "Access-Control-Request-Headers")
+
+		// answer the preflight without CORS headers so browser rejects the request
+		if !cors.methods[strings.ToUpper(requestMethod)] {
+			cors.D("%s preflight method %s from origin %s not allowed", context.RequestURI(), requestMethod, origin)
+			context.Response().WriteHeader(http.StatusNoContent)
+			return context.Success()
+		}
+	}
+
+	if cors.allowAll {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+
+	if cors.config.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+
+		if len(cors.config.ExposeHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(cors.config.ExposeHeaders, ", "))
+		}
+
+		return context.Forward()
+	}
+
+	header.Set("Access-Control-Allow-Methods", strings.Join(cors.config.AllowMethods, ", "))
+
+	if len(cors.config.AllowHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(cors.config.AllowHeaders, ", "))
+	} else if requestHeaders := request.Header.Get("Access-Control-Request-Headers"); requestHeaders != "" {
+		header.Set("Access-Control-Allow-Headers", requestHeaders)
+	}
+
+	if cors.config.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(cors.config.MaxAge/time.Second)))
+	}
+
+	context.Response().WriteHeader(http.StatusNoContent)
+
+	return context.Success()
+}
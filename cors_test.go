@@ -0,0 +1,172 @@
+package gsweb
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type corsTestHandler struct{}
+
+func (corsTestHandler) HandleGet(context *Context) error {
+	return context.Text(200, "ok")
+}
+
+func newCORSTestRouter(config CORSConfig) *Router {
+
+	router := newRouter()
+	router.ChainHandle("cors", NewCORS(config))
+
+	uri := NewURIHandler()
+	uri.Handle("/a", corsTestHandler{})
+	router.ChainHandle("uri", uri)
+
+	return router
+}
+
+func TestCORS(t *testing.T) {
+
+	listed := newCORSTestRouter(CORSConfig{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.example.org"},
+		AllowOriginRegex: []string{`https://pr-\d+\.example\.net`},
+		AllowMethods:     []string{"GET", "PUT"},
+		ExposeHeaders:    []string{"X-Total"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+
+	public := newCORSTestRouter(CORSConfig{
+		AllowOrigins: []string{"*"},
+		AllowHeaders: []string{"Content-Type"},
+	})
+
+	tests := []struct {
+		name    string
+		router  *Router
+		method  string
+		headers map[string]string // request headers
+		code    int
+		expect  map[string]string // expected response headers, empty value expects absent
+	}{
+		{
+			"same origin request",
+			listed, "GET", nil, 200,
+			map[string]string{"Access-Control-Allow-Origin": "", "Vary": "Origin"},
+		},
+		{
+			"exact origin",
+			listed, "GET", map[string]string{"Origin": "https://app.example.com"}, 200,
+			map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Expose-Headers":    "X-Total",
+				"Access-Control-Allow-Methods":     "",
+			},
+		},
+		{
+			"wildcard origin",
+			listed, "GET", map[string]string{"Origin": "https://api.example.org"}, 200,
+			map[string]string{"Access-Control-Allow-Origin": "https://api.example.org"},
+		},
+		{
+			"wildcard origin not matching suffix",
+			listed, "GET", map[string]string{"Origin": "https://example.org.evil.com"}, 200,
+			map[string]string{"Access-Control-Allow-Origin": ""},
+		},
+		{
+			"regex origin",
+			listed, "GET", map[string]string{"Origin": "https://pr-12.example.net"}, 200,
+			map[string]string{"Access-Control-Allow-Origin": "https://pr-12.example.net"},
+		},
+		{
+			"disallowed origin",
+			listed, "GET", map[string]string{"Origin": "https://evil.com"}, 200,
+			map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Credentials": ""},
+		},
+		{
+			"preflight",
+			listed, "OPTIONS",
+			map[string]string{
+				"Origin":                         "https://app.example.com",
+				"Access-Control-Request-Method":  "PUT",
+				"Access-Control-Request-Headers": "X-Token",
+			},
+			204,
+			map[string]string{
+				"Access-Control-Allow-Origin":      "https://app.example.com",
+				"Access-Control-Allow-Methods":     "GET, PUT",
+				"Access-Control-Allow-Headers":     "X-Token",
+				"Access-Control-Allow-Credentials": "true",
+				"Access-Control-Max-Age":           "600",
+				"Access-Control-Expose-Headers":    "",
+				"Vary":                             "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+		},
+		{
+			"preflight method not allowed",
+			listed, "OPTIONS",
+			map[string]string{"Origin": "https://app.example.com", "Access-Control-Request-Method": "DELETE"},
+			204,
+			map[string]string{"Access-Control-Allow-Origin": "", "Access-Control-Allow-Methods": ""},
+		},
+		{
+			"preflight disallowed origin",
+			listed, "OPTIONS",
+			map[string]string{"Origin": "https://evil.com", "Access-Control-Request-Method": "GET"},
+			204,
+			map[string]string{"Access-Control-Allow-Origin": "", "Allow": "GET, HEAD, OPTIONS"},
+		},
+		{
+			"any origin",
+			public, "GET", map[string]string{"Origin": "https://evil.com"}, 200,
+			map[string]string{"Access-Control-Allow-Origin": "*", "Access-Control-Allow-Credentials": "", "Vary": ""},
+		},
+		{
+			"any origin preflight",
+			public, "OPTIONS",
+			map[string]string{"Origin": "https://evil.com", "Access-Control-Request-Method": "POST", "Access-Control-Request-Headers": "X-Token"},
+			204,
+			map[string]string{
+				"Access-Control-Allow-Origin":  "*",
+				"Access-Control-Allow-Methods": "GET, HEAD, POST, PUT, PATCH, DELETE",
+				"Access-Control-Allow-Headers": "Content-Type",
+				"Access-Control-Max-Age":       "",
+			},
+		},
+	}
+
+	for _, test := range tests {
+
+		request := httptest.NewRequest(test.method, "/a", nil)
+
+		for name, value := range test.headers {
+			request.Header.Set(name, value)
+		}
+
+		recorder := httptest.NewRecorder()
+
+		test.router.ServeHTTP(recorder, request)
+
+		if recorder.Code != test.code {
+			t.Errorf("%s code got %d, expect %d", test.name, recorder.Code, test.code)
+		}
+
+		for name, expect := range test.expect {
+			if got := strings.Join(recorder.Header()[name], ", "); got != expect {
+				t.Errorf("%s header %s got %q, expect %q", test.name, name, got, expect)
+			}
+		}
+	}
+}
+
+func TestCORSWildcardCredentials(t *testing.T) {
+
+	defer func() {
+		if recover() == nil {
+			t.Error("* origin with credentials must be rejected")
+		}
+	}()
+
+	NewCORS(CORSConfig{AllowOrigins: []string{"*"}, AllowCredentials: true})
+}
@@ -0,0 +1,350 @@
+package gsweb
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gsdocker/gslogger"
+)
+
+const (
+	csrfSessionKey = "_csrf"
+	csrfTokenSize  = 32
+)
+
+// CSRFMode the CSRF token storage mode
+type CSRFMode int
+
+// CSRF token storage modes
+const (
+	CSRFSessionMode      CSRFMode = iota // token stored in session, requires Sessions chain node before CSRF
+	CSRFDoubleSubmitMode                 // token stored in cookie and submitted back by form field or header
+)
+
+// CSRFConfig the CSRF chain node config
+type CSRFConfig struct {
+	Mode           CSRFMode      // token storage mode, default CSRFSessionMode
+	CookieName     string        // double submit cookie name, default gsweb_csrf
+	Path           string        // double submit cookie path, default /
+	Domain         string        // double submit cookie domain
+	Secure         bool          // double submit cookie secure flag
+	SameSite       http.SameSite // double submit cookie SameSite attribute, default Lax
+	HeaderName     string        // token request header, default X-CSRF-Token
+	FieldName      string        // token form field, default csrf_token
+	TrustedOrigins []string      // origins allowed besides the request host, e.g. https://app.example.com
+	TrustedProxies []string      // trusted proxy ips or CIDRs, X-Forwarded-Proto and X-Forwarded-Host are honored for requests from them
+	ExemptPrefixes []string      // uri prefixes skipping verification, e.g. webhook endpoints
+}
+
+// CSRF the chain node verifying unsafe method requests carry the CSRF token
+// issued to the client and come from trusted origins
+type CSRF struct {
+	gslogger.Log                 // Mixin log APIs
+	config       CSRFConfig      // CSRF config
+	origins      map[string]bool // trusted origins
+	proxies      trustedProxies  // trusted proxies
+}
+
+// NewCSRF create CSRF chain node
+func NewCSRF(config CSRFConfig) *CSRF {
+
+	if config.CookieName == "" {
+		config.CookieName = "gsweb_csrf"
+	}
+
+	if config.Path == "" {
+		config.Path = "/"
+	}
+
+	if config.SameSite == 0 {
+		config.SameSite = http.SameSiteLaxMode
+	}
+
+	if config.HeaderName == "" {
+		config.HeaderName = "X-CSRF-Token"
+	}
+
+	if config.FieldName == "" {
+		config.FieldName = "csrf_token"
+	}
+
+	csrf := &CSRF{
+		Log:     gslogger.Get("csrf"),
+		config:  config,
+		origins: make(map[string]bool),
+		proxies: parseTrustedProxies(config.TrustedProxies),
+	}
+
+	for _, origin := range config.TrustedOrigins {
+		csrf.origins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+
+	return csrf
+}
+
+// HandleUnknown implement Unknown interface
+func (csrf *CSRF) HandleUnknown(context *Context) error {
+
+	context.csrf = csrf
+
+	switch context.RequestMethod() {
+	case "GET", "HEAD", "OPTIONS", "TRACE":
+		return context.Forward()
+	}
+
+	for _, prefix := range csrf.config.ExemptPrefixes {
+		if matchPrefix(context.RequestURI(), prefix) {
+			return context.Forward()
+		}
+	}
+
+	if err := csrf.checkOrigin(context); err != nil {
+		return context.Failed(err, "%s %s CSRF check error : %s", context.RequestMethod(), context.RequestURI(), err)
+	}
+
+	if err := csrf.checkToken(context); err != nil {
+		return context.Failed(err, "%s %s CSRF check error : %s", context.RequestMethod(), context.RequestURI(), err)
+	}
+
+	return context.Forward()
+}
+
+// requestOrigin get the origin of request target, the forwarded scheme and
+// host are used for requests from trusted proxies, e.g. TLS terminating proxy
+func (csrf *CSRF) requestOrigin(request *http.Request) string {
+
+	scheme, host := "http", request.Host
+
+	if request.TLS != nil {
+		scheme = "https"
+	}
+
+	if csrf.proxies.forwarded(request) {
+		if proto := firstHeaderValue(request, "X-Forwarded-Proto"); proto != "" {
+			scheme = proto
+		}
+
+		if forwardedHost := firstHeaderValue(request, "X-Forwarded-Host"); forwardedHost != "" {
+			host = forwardedHost
+		}
+	}
+
+	return strings.ToLower(scheme + "://" + host)
+}
+
+// firstHeaderValue get the first item of comma separated header, the one set
+// by the proxy nearest to client
+func firstHeaderValue(request *http.Request, name string) string {
+	return strings.TrimSpace(strings.Split(request.Header.Get(name), ",")[0])
+}
+
+// checkOrigin check the Origin header, or Referer header if Origin not sent,
+// matches the request host or trusted origins
+func (csrf *CSRF) checkOrigin(context *Context) error {
+
+	request := context.Request()
+
+	origin := request.Header.Get("Origin")
+
+	if origin == "" || origin == "null" {
+
+		referer := request.Referer()
+
+		if referer == "" {
+			if origin == "null" {
+				return NewHTTPError(http.StatusForbidden, nil, "CSRF check failed : opaque origin")
+			}
+
+			return nil
+		}
+
+		refererURL, err := url.Parse(referer)
+
+		if err != nil || refererURL.Host == "" {
+			return NewHTTPError(http.StatusForbidden, err, "CSRF check failed : invalid referer")
+		}
+
+		origin = refererURL.Scheme + "://" + refererURL.Host
+	}
+
+	origin = strings.ToLower(origin)
+
+	if origin != csrf.requestOrigin(request) && !csrf.origins[origin] {
+		return NewHTTPError(http.StatusForbidden, nil, "CSRF check failed : untrusted origin %s", origin)
+	}
+
+	return nil
+}
+
+// checkToken check the submitted token matches the issued one
+func (csrf *CSRF) checkToken(context *Context) error {
+
+	expected := csrf.storedToken(context)
+
+	if expected == nil {
+		return NewHTTPError(http.StatusForbidden, nil, "CSRF check failed : token not issued")
+	}
+
+	submitted := context.Request().Header.Get(csrf.config.HeaderName)
+
+	if submitted == "" {
+		submitted = context.Request().PostFormValue(csrf.config.FieldName)
+	}
+
+	token := csrf.submittedToken(submitted)
+
+	if token == nil || subtle.ConstantTimeCompare(token, expected) != 1 {
+		return NewHTTPError(http.StatusForbidden, nil, "CSRF check failed : token missing or invalid")
+	}
+
+	return nil
+}
+
+// storedToken get the issued token from session or cookie, returns nil if not issued
+func (csrf *CSRF) storedToken(context *Context) []byte {
+
+	var encoded string
+
+	if csrf.config.Mode == CSRFDoubleSubmitMode {
+		cookie, err := context.Request().Cookie(csrf.config.CookieName)
+
+		if err != nil {
+			return nil
+		}
+
+		encoded = cookie.Value
+	} else {
+		session := context.Session()
+
+		if session == nil {
+			csrf.E("CSRF session mode requires Sessions chain node before CSRF")
+			return nil
+		}
+
+		encoded, _ = session.Get(csrfSessionKey).(string)
+	}
+
+	token, err := base64.RawURLEncoding.DecodeString(encoded)
+
+	if err != nil || len(token) != csrfTokenSize {
+		return nil
+	}
+
+	return token
+}
+
+// issueToken get the issued token or issue new one
+func (csrf *CSRF) issueToken(context *Context) []byte {
+
+	if token := csrf.storedToken(context); token != nil {
+		return token
+	}
+
+	token := make([]byte, csrfTokenSize)
+
+	if _, err := rand.Read(token); err != nil {
+		panic(err)
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(token)
+
+	if csrf.config.Mode == CSRFDoubleSubmitMode {
+
+		// scripts read the cookie to submit it by header, so it's not HttpOnly
+		http.SetCookie(context.Response(), &http.Cookie{
+			Name:     csrf.config.CookieName,
+			Value:    encoded,
+			Path:     csrf.config.Path,
+			Domain:   csrf.config.Domain,
+			Secure:   csrf.config.Secure,
+			SameSite: csrf.config.SameSite,
+		})
+
+		// the following storedToken calls of the same request read the new token
+		context.Request().AddCookie(&http.Cookie{Name: csrf.config.CookieName, Value: encoded})
+	} else if session := context.Session(); session != nil {
+		session.Set(csrfSessionKey, encoded)
+	}
+
+	return token
+}
+
+// maskCSRFToken xor token with random pad, so the embedded token changes on
+// every response and can't be recovered by compression side channel
+func maskCSRFToken(token []byte) string {
+
+	masked := make([]byte, len(token)*2)
+
+	if _, err := rand.Read(masked[:len(token)]); err != nil {
+		panic(err)
+	}
+
+	for i, b := range token {
+		masked[len(token)+i] = b ^ masked[i]
+	}
+
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+// submittedToken decode the submitted token, the masked form is always
+// accepted, the raw cookie value is accepted in double submit mode so scripts
+// can copy the cookie into the header
+func (csrf *CSRF) submittedToken(text string) []byte {
+
+	decoded, err := base64.RawURLEncoding.DecodeString(text)
+
+	if err != nil {
+		return nil
+	}
+
+	switch len(decoded) {
+	case csrfTokenSize * 2:
+		return unmaskCSRFToken(decoded)
+	case csrfTokenSize:
+		if csrf.config.Mode == CSRFDoubleSubmitMode {
+			return decoded
+		}
+	}
+
+	return nil
+}
+
+func unmaskCSRFToken(masked []byte) []byte {
+
+	token := make([]byte, csrfTokenSize)
+
+	for i := range token {
+		token[i] = masked[i] ^ masked[csrfTokenSize+i]
+	}
+
+	return token
+}
+
+// CSRFToken get the masked CSRF token submitted by form field or request
+// header, the token is issued if not exists, returns empty string if no CSRF
+// chain node processed the request
+func (context *Context) CSRFToken() string {
+
+	if context.csrf == nil {
+		return ""
+	}
+
+	return maskCSRFToken(context.csrf.issueToken(context))
+}
+
+// CSRFField get the hidden form input carrying the CSRF token, embed it in
+// html templates' forms
+func (context *Context) CSRFField() template.HTML {
+
+	if context.csrf == nil {
+		return ""
+	}
+
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(context.csrf.config.FieldName) +
+		`" value="` + context.CSRFToken() + `">`)
+}
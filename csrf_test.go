@@ -0,0 +1,142 @@
+package gsweb
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type csrfTestHandler struct{}
+
+func (csrfTestHandler) HandleGet(context *Context) error {
+	return context.Text(200, "%s", context.CSRFToken())
+}
+
+func (csrfTestHandler) HandlePost(context *Context) error {
+	return context.Text(200, "ok")
+}
+
+type csrfTestCase struct {
+	name   string                      // case name
+	path   string                      // request path
+	field  string                      // token form field
+	header string                      // token header
+	modify func(request *http.Request) // request modifier
+	code   int                         // expected status code
+}
+
+func TestCSRFTokenMask(t *testing.T) {
+
+	csrf := NewCSRF(CSRFConfig{Mode: CSRFDoubleSubmitMode})
+
+	token := []byte("0123456789abcdef0123456789abcdef")
+
+	first, second := maskCSRFToken(token), maskCSRFToken(token)
+
+	if first == second {
+		t.Error("masked tokens must differ per call")
+	}
+
+	for _, masked := range []string{first, second} {
+		if got := csrf.submittedToken(masked); string(got) != string(token) {
+			t.Errorf("unmask %s got %q", masked, got)
+		}
+	}
+
+	for _, invalid := range []string{"", "not base64 !", "c2hvcnQ"} {
+		if got := csrf.submittedToken(invalid); got != nil {
+			t.Errorf("unmask %q got %q", invalid, got)
+		}
+	}
+}
+
+func TestCSRF(t *testing.T) {
+
+	for _, mode := range []CSRFMode{CSRFSessionMode, CSRFDoubleSubmitMode} {
+
+		router := newRouter()
+		router.ChainHandle("session", NewSessions(NewMemorySessionStore(16), SessionConfig{}))
+		router.ChainHandle("csrf", NewCSRF(CSRFConfig{
+			Mode:           mode,
+			TrustedOrigins: []string{"https://app.example.com"},
+			TrustedProxies: []string{"10.0.0.0/8"},
+			ExemptPrefixes: []string{"/hook"},
+		}))
+
+		uri := NewURIHandler()
+		uri.Handle("/form", csrfTestHandler{})
+		uri.Handle("/hook", csrfTestHandler{})
+		router.ChainHandle("uri", uri)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", "/form", nil))
+
+		token, cookies := recorder.Body.String(), recorder.Result().Cookies()
+
+		var rawCookie string
+
+		for _, cookie := range cookies {
+			if cookie.Name == "gsweb_csrf" {
+				rawCookie = cookie.Value
+			}
+		}
+
+		tests := []csrfTestCase{
+			{"form field", "/form", token, "", nil, 200},
+			{"header", "/form", "", token, nil, 200},
+			{"missing token", "/form", "", "", nil, 403},
+			{"invalid token", "/form", "invalid", "", nil, 403},
+			{"same origin", "/form", token, "", func(r *http.Request) { r.Header.Set("Origin", "http://example.com") }, 200},
+			{"trusted origin", "/form", token, "", func(r *http.Request) { r.Header.Set("Origin", "https://app.example.com") }, 200},
+			{"untrusted origin", "/form", token, "", func(r *http.Request) { r.Header.Set("Origin", "https://evil.com") }, 403},
+			{"referer", "/form", token, "", func(r *http.Request) { r.Header.Set("Referer", "https://evil.com/page") }, 403},
+			{"tls origin", "/form", token, "", func(r *http.Request) {
+				r.TLS = &tls.ConnectionState{}
+				r.Header.Set("Origin", "https://example.com")
+			}, 200},
+			{"trusted proxy origin", "/form", token, "", func(r *http.Request) {
+				r.RemoteAddr = "10.0.0.1:1234"
+				r.Header.Set("X-Forwarded-Proto", "https")
+				r.Header.Set("Origin", "https://example.com")
+			}, 200},
+			{"untrusted proxy origin", "/form", token, "", func(r *http.Request) {
+				r.Header.Set("X-Forwarded-Proto", "https")
+				r.Header.Set("Origin", "https://example.com")
+			}, 403},
+			{"exempt", "/hook", "", "", func(r *http.Request) { r.Header.Set("Origin", "https://evil.com") }, 200},
+		}
+
+		if mode == CSRFDoubleSubmitMode {
+			tests = append(tests, csrfTestCase{"raw cookie header", "/form", "", rawCookie, nil, 200})
+		}
+
+		for _, test := range tests {
+
+			request := httptest.NewRequest("POST", test.path, strings.NewReader(url.Values{"csrf_token": {test.field}}.Encode()))
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			if test.header != "" {
+				request.Header.Set("X-CSRF-Token", test.header)
+			}
+
+			for _, cookie := range cookies {
+				request.AddCookie(cookie)
+			}
+
+			if test.modify != nil {
+				test.modify(request)
+			}
+
+			recorder := httptest.NewRecorder()
+
+			router.ServeHTTP(recorder, request)
+
+			if recorder.Code != test.code {
+				t.Errorf("mode %d %s got %d, expect %d", mode, test.name, recorder.Code, test.code)
+			}
+		}
+	}
+}
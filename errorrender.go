@@ -0,0 +1,112 @@
+package gsweb
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+)
+
+// ErrorRenderer the error response renderer
+type ErrorRenderer interface {
+	RenderError(context *Context, err *HTTPError)
+}
+
+// ErrorRendererFunc the function adapter of ErrorRenderer
+type ErrorRendererFunc func(context *Context, err *HTTPError)
+
+// RenderError implement ErrorRenderer
+func (f ErrorRendererFunc) RenderError(context *Context, err *HTTPError) {
+	f(context, err)
+}
+
+var defaultErrorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>{{.Code}} {{.Title}}</title></head>
+<body>
+<h1>{{.Code}} {{.Title}}</h1>
+<p>{{.Message}}</p>
+{{if .Fields}}<ul>
+{{range .Fields}}<li>{{.Field}} : {{.Reason}}</li>
+{{end}}</ul>{{end}}
+</body>
+</html>
+`))
+
+// HTMLErrorRenderer render error as html page, the Template is executed with
+// an object has fields Code, Title, Message, Fields and Instance
+type HTMLErrorRenderer struct {
+	Template *template.Template // customer error page template, nil for default page
+}
+
+// RenderError implement ErrorRenderer
+func (renderer *HTMLErrorRenderer) RenderError(context *Context, err *HTTPError) {
+
+	page := renderer.Template
+
+	if page == nil {
+		page = defaultErrorPage
+	}
+
+	header := context.Response().Header()
+
+	header.Set("Content-Type", "text/html; charset=utf-8")
+	header.Set("X-Content-Type-Options", "nosniff")
+
+	context.Response().WriteHeader(err.Code)
+
+	model := struct {
+		Code     int
+		Title    string
+		Message  string
+		Fields   []FieldError
+		Instance string
+	}{
+		Code:     err.Code,
+		Title:    http.StatusText(err.Code),
+		Message:  err.Message,
+		Fields:   err.Fields,
+		Instance: context.RequestURI(),
+	}
+
+	if e := page.Execute(context.Response(), model); e != nil {
+		context.E("render error page error :%s", e)
+	}
+}
+
+// problem the RFC 7807 problem details object
+type problem struct {
+	Type          string       `json:"type"`
+	Title         string       `json:"title"`
+	Status        int          `json:"status"`
+	Detail        string       `json:"detail,omitempty"`
+	Instance      string       `json:"instance,omitempty"`
+	InvalidParams []FieldError `json:"invalid-params,omitempty"`
+}
+
+// JSONErrorRenderer render error as RFC 7807 application/problem+json document
+type JSONErrorRenderer struct {
+}
+
+// RenderError implement ErrorRenderer
+func (renderer *JSONErrorRenderer) RenderError(context *Context, err *HTTPError) {
+
+	header := context.Response().Header()
+
+	header.Set("Content-Type", "application/problem+json")
+	header.Set("X-Content-Type-Options", "nosniff")
+
+	context.Response().WriteHeader(err.Code)
+
+	content := &problem{
+		Type:          "about:blank",
+		Title:         http.StatusText(err.Code),
+		Status:        err.Code,
+		Detail:        err.Message,
+		Instance:      context.RequestURI(),
+		InvalidParams: err.Fields,
+	}
+
+	if e := json.NewEncoder(context.Response()).Encode(content); e != nil {
+		context.E("render error document error :%s", e)
+	}
+}
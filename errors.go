@@ -1 +1,66 @@
 package gsweb
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// HTTPError the error object carrying http status code, the public message
+// sent to client and the internal cause only written into log
+type HTTPError struct {
+	Code    int          // http status code
+	Message string       // public message
+	Cause   error        // internal cause
+	Fields  []FieldError // invalid request fields, public
+}
+
+// FieldError the invalid request field description
+type FieldError struct {
+	Field  string `json:"name"`   // field name
+	Reason string `json:"reason"` // invalid reason
+}
+
+// NewHTTPError create new HTTPError, the message is formatted by fmt and args,
+// if the message is empty the http.StatusText(code) is used
+func NewHTTPError(code int, cause error, format string, args ...interface{}) *HTTPError {
+
+	message := fmt.Sprintf(format, args...)
+
+	if message == "" {
+		message = http.StatusText(code)
+	}
+
+	return &HTTPError{
+		Code:    code,
+		Message: message,
+		Cause:   cause,
+	}
+}
+
+// Error implement error interface
+func (err *HTTPError) Error() string {
+	if err.Cause != nil {
+		return fmt.Sprintf("%d %s : %s", err.Code, err.Message, err.Cause)
+	}
+
+	return fmt.Sprintf("%d %s", err.Code, err.Message)
+}
+
+// Unwrap implement errors.Unwrap protocol
+func (err *HTTPError) Unwrap() error {
+	return err.Cause
+}
+
+// AsHTTPError convert err to HTTPError, errors not wrapping HTTPError are
+// mapped to 500 Internal Server Error
+func AsHTTPError(err error) *HTTPError {
+
+	var httpError *HTTPError
+
+	if errors.As(err, &httpError) {
+		return httpError
+	}
+
+	return NewHTTPError(http.StatusInternalServerError, err, "")
+}
@@ -0,0 +1,195 @@
+package gsweb
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// errorTestHandlers the handlers failing in different ways, indexed by path
+var errorTestHandlers = map[string]MethodHandler{
+	"/panic": func(context *Context) error {
+		panic("boom")
+	},
+	"/panic-error": func(context *Context) error {
+		panic(errors.New("boom"))
+	},
+	"/plain": func(context *Context) error {
+		return errors.New("plain error")
+	},
+	"/invalid": func(context *Context) error {
+		err := NewHTTPError(http.StatusBadRequest, nil, "invalid <user>")
+		err.Fields = []FieldError{{Field: "name", Reason: "required"}}
+		return err
+	},
+	"/written": func(context *Context) error {
+		context.Text(http.StatusAccepted, "partial")
+		panic("after write")
+	},
+}
+
+func newErrorTestRouter(renderer ErrorRenderer) *Router {
+
+	router := newRouter()
+
+	if renderer != nil {
+		router.SetErrorRenderer(renderer)
+	}
+
+	uri := NewURIHandler()
+
+	for path, handler := range errorTestHandlers {
+		uri.Handle(path, Methods{"GET": handler})
+	}
+
+	router.ChainHandle("uri", uri)
+
+	return router
+}
+
+func TestHTTPError(t *testing.T) {
+
+	cause := errors.New("cause")
+
+	err := NewHTTPError(http.StatusNotFound, cause, "user %d", 1)
+
+	if err.Error() != "404 user 1 : cause" {
+		t.Errorf("error string got %s", err.Error())
+	}
+
+	if !errors.Is(err, cause) {
+		t.Error("http error must unwrap its cause")
+	}
+
+	if err := NewHTTPError(http.StatusConflict, nil, ""); err.Message != "Conflict" {
+		t.Errorf("default message got %s", err.Message)
+	}
+
+	if got := AsHTTPError(err); got != err {
+		t.Error("AsHTTPError must return the http error itself")
+	}
+
+	if got := AsHTTPError(cause); got.Code != http.StatusInternalServerError || got.Cause != cause {
+		t.Errorf("AsHTTPError plain error got %s", got)
+	}
+}
+
+func TestHTMLErrorRenderer(t *testing.T) {
+
+	router := newErrorTestRouter(nil)
+
+	tests := []struct {
+		path     string
+		code     int
+		contains []string
+	}{
+		{"/panic", 500, []string{"<h1>500 Internal Server Error</h1>"}},
+		{"/panic-error", 500, []string{"<h1>500 Internal Server Error</h1>"}},
+		{"/plain", 500, []string{"<h1>500 Internal Server Error</h1>"}},
+		{"/invalid", 400, []string{"<h1>400 Bad Request</h1>", "invalid &lt;user&gt;", "<li>name : required</li>"}},
+		{"/written", 202, []string{"partial"}},
+	}
+
+	for _, test := range tests {
+
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", test.path, nil))
+
+		if recorder.Code != test.code {
+			t.Errorf("%s code got %d, expect %d", test.path, recorder.Code, test.code)
+		}
+
+		body := recorder.Body.String()
+
+		for _, expect := range test.contains {
+			if !strings.Contains(body, expect) {
+				t.Errorf("%s body %q not contains %q", test.path, body, expect)
+			}
+		}
+
+		if strings.Contains(body, "boom") || strings.Contains(body, "plain error") {
+			t.Errorf("%s body leaks internal error : %s", test.path, body)
+		}
+	}
+}
+
+func TestJSONErrorRenderer(t *testing.T) {
+
+	router := newErrorTestRouter(&JSONErrorRenderer{})
+
+	tests := []struct {
+		path   string
+		expect problem
+	}{
+		{
+			"/panic",
+			problem{Type: "about:blank", Title: "Internal Server Error", Status: 500, Detail: "Internal Server Error", Instance: "/panic"},
+		},
+		{
+			"/invalid",
+			problem{
+				Type:          "about:blank",
+				Title:         "Bad Request",
+				Status:        400,
+				Detail:        "invalid <user>",
+				Instance:      "/invalid",
+				InvalidParams: []FieldError{{Field: "name", Reason: "required"}},
+			},
+		},
+		{
+			"/missing",
+			problem{Type: "about:blank", Title: "Not Found", Status: 404, Detail: "Not Found", Instance: "/missing"},
+		},
+	}
+
+	for _, test := range tests {
+
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", test.path, nil))
+
+		if recorder.Code != test.expect.Status {
+			t.Errorf("%s code got %d", test.path, recorder.Code)
+		}
+
+		if got := recorder.Header().Get("Content-Type"); got != "application/problem+json" {
+			t.Errorf("%s content type got %s", test.path, got)
+		}
+
+		var got problem
+
+		if err := json.Unmarshal(recorder.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s decode problem error : %s", test.path, err)
+		}
+
+		expect, _ := json.Marshal(test.expect)
+		actual, _ := json.Marshal(got)
+
+		if string(expect) != string(actual) {
+			t.Errorf("%s problem got %s, expect %s", test.path, actual, expect)
+		}
+	}
+}
+
+func TestPanicAbortHandler(t *testing.T) {
+
+	router := newRouter()
+
+	uri := NewURIHandler()
+	uri.Handle("/abort", Methods{"GET": func(context *Context) error {
+		panic(http.ErrAbortHandler)
+	}})
+	router.ChainHandle("uri", uri)
+
+	defer func() {
+		if e := recover(); e != http.ErrAbortHandler {
+			t.Errorf("ErrAbortHandler must be re-panicked, got %v", e)
+		}
+	}()
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort", nil))
+}
@@ -1,7 +1,9 @@
 package gsweb
 
 import (
+	"mime"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
 
@@ -11,9 +13,11 @@ import (
 
 // RegisterPath the file handler's register path object
 type RegisterPath struct {
-	enableListChild bool         // Indicate if allow list dir child items
-	path            string       // The fileHandler path name
-	handler         http.Handler // the fileHandler path's handler
+	enableListChild     bool         // Indicate if allow list dir child items
+	enablePrecompressed bool         // Indicate if serve precompressed .br/.gz siblings
+	path                string       // The fileHandler path name
+	root                string       // The absolute path of the fileHandler path
+	handler             http.Handler // the fileHandler path's handler
 }
 
 // EnableGetDir set flag, true enable list directory's child items,otherwise
@@ -22,6 +26,12 @@ func (path *RegisterPath) EnableGetDir(flag bool) {
 	path.enableListChild = flag
 }
 
+// EnablePrecompressed set flag, true serve the precompressed .br or .gz
+// sibling of the requested file if exists and accepted by client
+func (path *RegisterPath) EnablePrecompressed(flag bool) {
+	path.enablePrecompressed = flag
+}
+
 // FileHandler The static fileHandler handler
 type FileHandler struct {
 	gslogger.Log                           // Mixin log APIs
@@ -76,6 +86,14 @@ func (fileHandler *FileHandler) HandleGet(context *Context) error {
 
 		fileHandler.D("GET %s handler -- found", uri)
 
+		if metrics := context.Router.metrics; metrics != nil {
+			metrics.fileRequest(matchedPrefix, true)
+		}
+
+		if registerPath.enablePrecompressed && fileHandler.servePrecompressed(context, registerPath, uri[len(matchedPrefix):]) {
+			return context.Success()
+		}
+
 		registerPath.handler.ServeHTTP(context.Response(), context.Request())
 
 		return context.Success()
@@ -83,6 +101,10 @@ func (fileHandler *FileHandler) HandleGet(context *Context) error {
 
 FORWARD:
 
+	if metrics := context.Router.metrics; metrics != nil {
+		metrics.fileRequest(matchedPrefix, false)
+	}
+
 	// forward this request to next chain handler
 	err := context.Forward()
 
@@ -103,7 +125,7 @@ func (fileHandler *FileHandler) RegisterPath(uriprefix string, dir string) (*Reg
 		return nil, err
 	}
 
-	registerPath := &RegisterPath{path: dir, handler: http.FileServer(http.Dir(path))}
+	registerPath := &RegisterPath{path: dir, root: path, handler: http.FileServer(http.Dir(path))}
 
 	fileHandler.registerPaths[uriprefix] = registerPath
 
@@ -111,3 +133,84 @@ func (fileHandler *FileHandler) RegisterPath(uriprefix string, dir string) (*Reg
 
 	return registerPath, nil
 }
+
+// precompressedEncodings the precompressed file suffixes by content encoding
+var precompressedEncodings = []struct {
+	encoding string // content encoding
+	suffix   string // file suffix
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// servePrecompressed serve the precompressed sibling of the file at uri path
+// relative to register path, returns false if no acceptable sibling exists
+func (fileHandler *FileHandler) servePrecompressed(context *Context, registerPath *RegisterPath, relative string) bool {
+
+	// clean the path as http.FileServer does so it can't escape the root
+	path := filepath.Join(registerPath.root, filepath.Clean(string(filepath.Separator)+filepath.FromSlash(relative)))
+
+	// the register root itself is a directory, not an escape
+	if path == registerPath.root {
+		return false
+	}
+
+	root := registerPath.root
+
+	if !strings.HasSuffix(root, string(filepath.Separator)) {
+		root += string(filepath.Separator)
+	}
+
+	if !strings.HasPrefix(path, root) {
+		fileHandler.W("GET %s refuse precompressed path outside %s", context.RequestURI(), registerPath.root)
+		return false
+	}
+
+	if fs.IsDir(path) {
+		return false
+	}
+
+	contentType := mime.TypeByExtension(filepath.Ext(path))
+
+	if contentType == "" {
+		return false
+	}
+
+	header := context.Response().Header()
+
+	header.Add("Vary", "Accept-Encoding")
+
+	accepted := context.Request().Header.Get("Accept-Encoding")
+
+	for _, precompressed := range precompressedEncodings {
+
+		if negotiateEncoding(accepted, []string{precompressed.encoding}) == "" {
+			continue
+		}
+
+		file, err := os.Open(path + precompressed.suffix)
+
+		if err != nil {
+			continue
+		}
+
+		defer file.Close()
+
+		info, err := file.Stat()
+
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		fileHandler.D("GET %s serve precompressed %s", context.RequestURI(), file.Name())
+
+		header.Set("Content-Type", contentType)
+		header.Set("Content-Encoding", precompressed.encoding)
+
+		http.ServeContent(context.Response(), context.Request(), path, info.ModTime(), file)
+
+		return true
+	}
+
+	return false
+}
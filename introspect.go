@@ -0,0 +1,217 @@
+package gsweb
+
+import (
+	"fmt"
+	"html/template"
+	"sort"
+
+	"github.com/gsdocker/gslogger"
+)
+
+// RouteInfo the route registered under chain node
+type RouteInfo struct {
+	Pattern    string                `json:"pattern"`          // uri pattern or uri prefix
+	Methods    []string              `json:"methods"`          // supported methods
+	Target     string                `json:"target,omitempty"` // route target description, e.g. directory
+	Operations map[string]*Operation `json:"-"`                // api documents indexed by method
+}
+
+// ChainInfo the chain node description
+type ChainInfo struct {
+	Name    string      `json:"name"`             // chain node name
+	Type    string      `json:"type"`             // handler object type
+	Methods []string    `json:"methods"`          // supported methods
+	Routes  []RouteInfo `json:"routes,omitempty"` // routes registered underneath
+}
+
+// RouteLister chain handlers implement this interface report the routes
+// registered underneath to Router.Introspect
+type RouteLister interface {
+	Routes() []RouteInfo
+}
+
+// handlerMethods get sorted method names of handlers, include UNKNOWN
+func handlerMethods(handlers map[string]MethodHandler) []string {
+	methods := make([]string, 0, len(handlers))
+
+	for name := range handlers {
+		methods = append(methods, name)
+	}
+
+	sort.Strings(methods)
+
+	return methods
+}
+
+// Introspect get the current handle chain description
+func (router *Router) Introspect() []ChainInfo {
+
+	chain := router.chain()
+
+	infos := make([]ChainInfo, 0, len(chain))
+
+	for _, handler := range chain {
+
+		info := ChainInfo{
+			Name:    handler.name,
+			Type:    fmt.Sprintf("%T", handler.target),
+			Methods: handlerMethods(handler.methods),
+		}
+
+		if lister, ok := handler.target.(RouteLister); ok {
+			info.Routes = lister.Routes()
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos
+}
+
+// Routes implement RouteLister
+func (uri *URIHandler) Routes() []RouteInfo {
+
+	var routes []RouteInfo
+
+	uri.tree.walk(func(route *Route) {
+		routes = append(routes, RouteInfo{
+			Pattern:    route.pattern,
+			Methods:    handlerMethods(route.methods),
+			Operations: route.operations,
+		})
+	})
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Pattern < routes[j].Pattern
+	})
+
+	return routes
+}
+
+// walk call f with every route in the tree
+func (node *uriNode) walk(f func(route *Route)) {
+
+	if node.route != nil {
+		f(node.route)
+	}
+
+	for _, child := range node.children {
+		child.walk(f)
+	}
+
+	for _, param := range node.params {
+		param.next.walk(f)
+	}
+
+	if node.catchAll != nil {
+		node.catchAll.next.walk(f)
+	}
+}
+
+// Routes implement RouteLister
+func (fileHandler *FileHandler) Routes() []RouteInfo {
+
+	var routes []RouteInfo
+
+	for prefix, path := range fileHandler.registerPaths {
+		routes = append(routes, RouteInfo{
+			Pattern: prefix,
+			Methods: []string{"GET", "HEAD"},
+			Target:  path.path,
+		})
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Pattern < routes[j].Pattern
+	})
+
+	return routes
+}
+
+// Routes implement RouteLister
+func (handler *middlewareHandler) Routes() []RouteInfo {
+
+	if handler.prefix == "" {
+		return nil
+	}
+
+	return []RouteInfo{{Pattern: handler.prefix, Methods: []string{"UNKNOWN"}, Target: "middleware scope"}}
+}
+
+var routesPage = template.Must(template.New("routes").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>gsweb routes</title>
+<style nonce="{{.Nonce}}">
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #f0f0f0; }
+code { font-family: monospace; }
+</style>
+</head>
+<body>
+<h1>gsweb routes</h1>
+{{range $i, $node := .Nodes}}
+<h2>{{$i}}. {{$node.Name}} <small><code>{{$node.Type}}</code></small></h2>
+<p>chain methods : {{range $node.Methods}}<code>{{.}}</code> {{end}}</p>
+{{if $node.Routes}}
+<table>
+<tr><th>pattern</th><th>methods</th><th>target</th></tr>
+{{range $node.Routes}}<tr><td><code>{{.Pattern}}</code></td><td>{{range .Methods}}<code>{{.}}</code> {{end}}</td><td>{{.Target}}</td></tr>
+{{end}}
+</table>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// RoutesHandler the chain node serving the live routing table as html page,
+// or json document when requested by ?format=json or Accept header
+type RoutesHandler struct {
+	gslogger.Log        // Mixin log APIs
+	path         string // the mounted uri
+}
+
+// NewRoutesHandler create routes handler mounted on path, e.g. /_gsweb/routes
+func NewRoutesHandler(path string) *RoutesHandler {
+	return &RoutesHandler{
+		Log:  gslogger.Get("routes"),
+		path: path,
+	}
+}
+
+// HandleGet implement Get interface
+func (handler *RoutesHandler) HandleGet(context *Context) error {
+
+	if context.RequestURI() != handler.path {
+		return context.Forward()
+	}
+
+	infos := context.Router.Introspect()
+
+	format := context.Request().URL.Query().Get("format")
+
+	if format == "" {
+		format = "html"
+
+		offer := negotiateContentType(context.Request().Header.Get("Accept"), []string{"text/html", "application/json"})
+
+		if offer == "application/json" {
+			format = "json"
+		}
+	}
+
+	context.Response().Header().Set("Cache-Control", "no-store")
+
+	if format == "json" {
+		return context.JSON(200, infos)
+	}
+
+	return context.HTML(200, routesPage, map[string]interface{}{
+		"Nodes": infos,
+		"Nonce": context.CSPNonce(),
+	})
+}
@@ -0,0 +1,147 @@
+package gsweb
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type introspectTestHandler struct{}
+
+func (introspectTestHandler) HandleGet(context *Context) error {
+	return context.Text(200, "ok")
+}
+
+func (introspectTestHandler) HandlePost(context *Context) error {
+	return context.Text(200, "ok")
+}
+
+func newIntrospectTestRouter() *Router {
+
+	router := newRouter()
+	router.ChainHandle("security", NewSecurityHeaders(SecurityHeadersConfig{}))
+	router.ChainHandle("routes", NewRoutesHandler("/_gsweb/routes"))
+	router.Group("/api").Use("api", Middleware{})
+
+	files := NewFileHandler()
+	files.RegisterPath("/static/", "static")
+	router.ChainHandle("files", files)
+
+	uri := NewURIHandler()
+	uri.Handle("/users/:id", introspectTestHandler{})
+	uri.HandleMethod("DELETE", "/users/:id", introspectTestHandler{}.HandleGet)
+	uri.Handle("/api/*path", introspectTestHandler{})
+	router.ChainHandle("uri", uri)
+
+	return router
+}
+
+func TestIntrospect(t *testing.T) {
+
+	// the routes are described as pattern:methods:target
+	describe := func(routes []RouteInfo) string {
+
+		var items []string
+
+		for _, route := range routes {
+			items = append(items, route.Pattern+":"+strings.Join(route.Methods, ",")+":"+route.Target)
+		}
+
+		return strings.Join(items, " ")
+	}
+
+	expect := []struct {
+		name    string
+		kind    string
+		methods string
+		routes  string
+	}{
+		{"security", "*gsweb.SecurityHeaders", "UNKNOWN", ""},
+		{"routes", "*gsweb.RoutesHandler", "GET,HEAD", ""},
+		{"api", "*gsweb.middlewareHandler", "UNKNOWN", "/api:UNKNOWN:middleware scope"},
+		{"files", "*gsweb.FileHandler", "GET,HEAD", "/static/:GET,HEAD:static"},
+		{"uri", "*gsweb.URIHandler", "UNKNOWN", "/api/*path:GET,HEAD,POST: /users/:id:DELETE,GET,HEAD,POST:"},
+	}
+
+	infos := newIntrospectTestRouter().Introspect()
+
+	if len(infos) != len(expect) {
+		t.Fatalf("introspect got %d nodes, expect %d", len(infos), len(expect))
+	}
+
+	for i, info := range infos {
+
+		got := []string{info.Name, info.Type, strings.Join(info.Methods, ","), describe(info.Routes)}
+		want := []string{expect[i].name, expect[i].kind, expect[i].methods, expect[i].routes}
+
+		if strings.Join(got, "|") != strings.Join(want, "|") {
+			t.Errorf("node %d got %q, expect %q", i, got, want)
+		}
+	}
+}
+
+func TestRoutesHandler(t *testing.T) {
+
+	router := newIntrospectTestRouter()
+
+	tests := []struct {
+		name   string
+		query  string
+		accept string
+		json   bool
+	}{
+		{"default html", "", "", false},
+		{"browser accept", "", "text/html,application/xhtml+xml,*/*;q=0.8", false},
+		{"json accept", "", "application/json", true},
+		{"json format", "?format=json", "text/html", true},
+		{"html format", "?format=html", "application/json", false},
+	}
+
+	for _, test := range tests {
+
+		request := httptest.NewRequest("GET", "/_gsweb/routes"+test.query, nil)
+
+		if test.accept != "" {
+			request.Header.Set("Accept", test.accept)
+		}
+
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != 200 {
+			t.Errorf("%s code got %d", test.name, recorder.Code)
+			continue
+		}
+
+		if got := recorder.Header().Get("Cache-Control"); got != "no-store" {
+			t.Errorf("%s cache control got %q", test.name, got)
+		}
+
+		body := recorder.Body.String()
+
+		if test.json {
+
+			var infos []ChainInfo
+
+			if err := json.Unmarshal([]byte(body), &infos); err != nil || len(infos) != 5 || infos[4].Routes[1].Pattern != "/users/:id" {
+				t.Errorf("%s json got %s, error %v", test.name, body, err)
+			}
+
+			continue
+		}
+
+		if !strings.Contains(body, "<code>/users/:id</code>") {
+			t.Errorf("%s html missing route : %s", test.name, body)
+		}
+
+		// the inline style is allowed by the default nonce based policy
+		nonce := regexp.MustCompile(`<style nonce="([^"]+)">`).FindStringSubmatch(body)
+
+		if nonce == nil || !strings.Contains(recorder.Header().Get("Content-Security-Policy"), "style-src 'self' 'nonce-"+nonce[1]+"'") {
+			t.Errorf("%s style nonce %v not allowed by %q", test.name, nonce, recorder.Header().Get("Content-Security-Policy"))
+		}
+	}
+}
@@ -0,0 +1,564 @@
+package gsweb
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // register SHA-256 for crypto.Hash
+	_ "crypto/sha512" // register SHA-384 and SHA-512 for crypto.Hash
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gsdocker/gserrors"
+	"github.com/gsdocker/gslogger"
+)
+
+// jwtAlgorithm the JWS signature algorithm
+type jwtAlgorithm struct {
+	family string      // HS, RS, PS or ES
+	hash   crypto.Hash // digest hash
+	size   int         // ECDSA key size in bytes
+}
+
+var jwtAlgorithms = map[string]jwtAlgorithm{
+	"HS256": {"HS", crypto.SHA256, 0},
+	"HS384": {"HS", crypto.SHA384, 0},
+	"HS512": {"HS", crypto.SHA512, 0},
+	"RS256": {"RS", crypto.SHA256, 0},
+	"RS384": {"RS", crypto.SHA384, 0},
+	"RS512": {"RS", crypto.SHA512, 0},
+	"PS256": {"PS", crypto.SHA256, 0},
+	"PS384": {"PS", crypto.SHA384, 0},
+	"PS512": {"PS", crypto.SHA512, 0},
+	"ES256": {"ES", crypto.SHA256, 32},
+	"ES384": {"ES", crypto.SHA384, 48},
+	"ES512": {"ES", crypto.SHA512, 66},
+}
+
+// JWTConfig the JWT authentication provider config
+type JWTConfig struct {
+	Realm        string            // authentication realm
+	Issuer       string            // required iss claim, empty skips the check
+	Audience     string            // required aud claim, empty skips the check
+	Algorithms   []string          // accepted algorithms, default every supported algorithm
+	Leeway       time.Duration     // clock skew tolerance of exp, nbf and iat
+	HMACKeys     map[string][]byte // HMAC secrets indexed by kid, empty kid matches tokens without kid
+	JWKSFile     string            // local JWKS file path providing RSA, EC and oct keys, reloaded when modified
+	SubjectClaim string            // the string claim used as principal subject, tokens without it are rejected, default sub
+}
+
+// jwtKey the verification key
+type jwtKey struct {
+	id  string      // key id
+	alg string      // key algorithm restriction, may be empty
+	key interface{} // []byte, *rsa.PublicKey or *ecdsa.PublicKey
+}
+
+// JWTAuth the bearer JWT authentication provider
+type JWTAuth struct {
+	gslogger.Log                 // Mixin log APIs
+	config       JWTConfig       // JWT config
+	algorithms   map[string]bool // accepted algorithms
+	mutex        sync.Mutex      // keys guard
+	keys         []*jwtKey       // verification keys, HMAC keys first
+	fileKeys     int             // the count of keys loaded from JWKS file, at the tail of keys
+	modTime      time.Time       // loaded JWKS file modification time
+	lastCheck    time.Time       // last JWKS file modification check time
+}
+
+// NewJWTAuth create JWT authentication provider
+func NewJWTAuth(config JWTConfig) (*JWTAuth, error) {
+
+	if config.SubjectClaim == "" {
+		config.SubjectClaim = "sub"
+	}
+
+	jwt := &JWTAuth{
+		Log:        gslogger.Get("auth"),
+		config:     config,
+		algorithms: make(map[string]bool),
+	}
+
+	if len(config.Algorithms) == 0 {
+		for name := range jwtAlgorithms {
+			jwt.algorithms[name] = true
+		}
+	}
+
+	for _, name := range config.Algorithms {
+
+		if _, ok := jwtAlgorithms[name]; !ok {
+			return nil, gserrors.Newf(nil, "unsupported JWT algorithm %s", name)
+		}
+
+		jwt.algorithms[name] = true
+	}
+
+	for id, secret := range config.HMACKeys {
+		jwt.keys = append(jwt.keys, &jwtKey{id: id, key: secret})
+	}
+
+	if config.JWKSFile != "" {
+		if err := jwt.loadJWKS(); err != nil {
+			return nil, err
+		}
+	}
+
+	return jwt, nil
+}
+
+// jwk the JSON web key
+type jwk struct {
+	Kty string `json:"kty"` // key type, RSA, EC or oct
+	Kid string `json:"kid"` // key id
+	Alg string `json:"alg"` // key algorithm
+	Use string `json:"use"` // key usage
+	N   string `json:"n"`   // RSA modulus
+	E   string `json:"e"`   // RSA exponent
+	Crv string `json:"crv"` // EC curve
+	X   string `json:"x"`   // EC x coordinate
+	Y   string `json:"y"`   // EC y coordinate
+	K   string `json:"k"`   // symmetric key
+}
+
+func decodeBigInt(text string) (*big.Int, error) {
+
+	buff, err := base64.RawURLEncoding.DecodeString(text)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(buff), nil
+}
+
+// parseJWK parse JSON web key into verification key
+func parseJWK(key *jwk) (*jwtKey, error) {
+
+	switch key.Kty {
+	case "RSA":
+		n, err := decodeBigInt(key.N)
+
+		if err != nil {
+			return nil, gserrors.Newf(err, "invalid RSA key %s modulus", key.Kid)
+		}
+
+		e, err := decodeBigInt(key.E)
+
+		if err != nil || !e.IsInt64() || e.Int64() < 3 {
+			return nil, gserrors.Newf(err, "invalid RSA key %s exponent", key.Kid)
+		}
+
+		return &jwtKey{id: key.Kid, alg: key.Alg, key: &rsa.PublicKey{N: n, E: int(e.Int64())}}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+
+		switch key.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, gserrors.Newf(nil, "unsupported EC key %s curve %s", key.Kid, key.Crv)
+		}
+
+		x, err := decodeBigInt(key.X)
+
+		if err != nil {
+			return nil, gserrors.Newf(err, "invalid EC key %s x", key.Kid)
+		}
+
+		y, err := decodeBigInt(key.Y)
+
+		if err != nil {
+			return nil, gserrors.Newf(err, "invalid EC key %s y", key.Kid)
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return nil, gserrors.Newf(nil, "invalid EC key %s point", key.Kid)
+		}
+
+		return &jwtKey{id: key.Kid, alg: key.Alg, key: &ecdsa.PublicKey{Curve: curve, X: x, Y: y}}, nil
+
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(key.K)
+
+		if err != nil || len(secret) == 0 {
+			return nil, gserrors.Newf(err, "invalid oct key %s", key.Kid)
+		}
+
+		return &jwtKey{id: key.Kid, alg: key.Alg, key: secret}, nil
+	}
+
+	return nil, gserrors.Newf(nil, "unsupported key %s type %s", key.Kid, key.Kty)
+}
+
+// loadJWKS load JWKS file replacing the keys loaded before, must be called
+// with mutex held or before shared
+func (jwt *JWTAuth) loadJWKS() error {
+
+	info, err := os.Stat(jwt.config.JWKSFile)
+
+	if err != nil {
+		return gserrors.Newf(err, "stat JWKS file %s error", jwt.config.JWKSFile)
+	}
+
+	content, err := os.ReadFile(jwt.config.JWKSFile)
+
+	if err != nil {
+		return gserrors.Newf(err, "read JWKS file %s error", jwt.config.JWKSFile)
+	}
+
+	var set struct {
+		Keys []*jwk `json:"keys"`
+	}
+
+	if err := json.Unmarshal(content, &set); err != nil {
+		return gserrors.Newf(err, "decode JWKS file %s error", jwt.config.JWKSFile)
+	}
+
+	keys := append([]*jwtKey(nil), jwt.keys[:len(jwt.keys)-jwt.fileKeys]...)
+
+	loaded := 0
+
+	for _, key := range set.Keys {
+
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		parsed, err := parseJWK(key)
+
+		if err != nil {
+			jwt.W("skip JWKS key : %s", err)
+			continue
+		}
+
+		keys = append(keys, parsed)
+		loaded++
+	}
+
+	jwt.keys = keys
+	jwt.fileKeys = loaded
+	jwt.modTime = info.ModTime()
+	jwt.lastCheck = time.Now()
+
+	return nil
+}
+
+// candidates get the keys may verify token signed by alg with kid, reload
+// JWKS file if modified
+func (jwt *JWTAuth) candidates(alg string, kid string) []*jwtKey {
+	jwt.mutex.Lock()
+	defer jwt.mutex.Unlock()
+
+	if jwt.config.JWKSFile != "" && time.Since(jwt.lastCheck) > authFileCheckPeriod {
+
+		jwt.lastCheck = time.Now()
+
+		if info, err := os.Stat(jwt.config.JWKSFile); err == nil && !info.ModTime().Equal(jwt.modTime) {
+			jwt.I("JWKS file %s changed, reload", jwt.config.JWKSFile)
+
+			if err := jwt.loadJWKS(); err != nil {
+				jwt.E("reload JWKS file error : %s", err)
+			}
+		}
+	}
+
+	family := jwtAlgorithms[alg].family
+
+	var keys []*jwtKey
+
+	for _, key := range jwt.keys {
+
+		if key.id != kid || (key.alg != "" && key.alg != alg) {
+			continue
+		}
+
+		// the key type must match the algorithm family, prevents algorithm confusion
+		switch key.key.(type) {
+		case []byte:
+			if family != "HS" {
+				continue
+			}
+		case *rsa.PublicKey:
+			if family != "RS" && family != "PS" {
+				continue
+			}
+		case *ecdsa.PublicKey:
+			if family != "ES" {
+				continue
+			}
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// verifySignature verify JWS signature of signing input by key
+func verifySignature(algorithm jwtAlgorithm, key interface{}, input []byte, signature []byte) bool {
+
+	if algorithm.family == "HS" {
+		mac := hmac.New(algorithm.hash.New, key.([]byte))
+		mac.Write(input)
+		return hmac.Equal(mac.Sum(nil), signature)
+	}
+
+	digest := algorithm.hash.New()
+	digest.Write(input)
+	hashed := digest.Sum(nil)
+
+	switch algorithm.family {
+	case "RS":
+		return rsa.VerifyPKCS1v15(key.(*rsa.PublicKey), algorithm.hash, hashed, signature) == nil
+
+	case "PS":
+		return rsa.VerifyPSS(key.(*rsa.PublicKey), algorithm.hash, hashed, signature, nil) == nil
+
+	case "ES":
+		publicKey := key.(*ecdsa.PublicKey)
+
+		if len(signature) != 2*algorithm.size || (publicKey.Curve.Params().BitSize+7)/8 != algorithm.size {
+			return false
+		}
+
+		r := new(big.Int).SetBytes(signature[:algorithm.size])
+		s := new(big.Int).SetBytes(signature[algorithm.size:])
+
+		return ecdsa.Verify(publicKey, hashed, r, s)
+	}
+
+	return false
+}
+
+// Verify verify JWT signature and registered claims, returns the claims
+func (jwt *JWTAuth) Verify(token string) (map[string]interface{}, error) {
+
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 {
+		return nil, gserrors.Newf(nil, "malformed JWT")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+
+	if err != nil {
+		return nil, gserrors.Newf(err, "malformed JWT header")
+	}
+
+	var header struct {
+		Alg  string   `json:"alg"`
+		Kid  string   `json:"kid"`
+		Crit []string `json:"crit"`
+	}
+
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, gserrors.Newf(err, "malformed JWT header")
+	}
+
+	if len(header.Crit) > 0 {
+		return nil, gserrors.Newf(nil, "unsupported JWT critical headers %v", header.Crit)
+	}
+
+	algorithm, ok := jwtAlgorithms[header.Alg]
+
+	if !ok || !jwt.algorithms[header.Alg] {
+		return nil, gserrors.Newf(nil, "unaccepted JWT algorithm %s", header.Alg)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+
+	if err != nil {
+		return nil, gserrors.Newf(err, "malformed JWT signature")
+	}
+
+	input := []byte(parts[0] + "." + parts[1])
+
+	verified := false
+
+	for _, key := range jwt.candidates(header.Alg, header.Kid) {
+		if verifySignature(algorithm, key.key, input, signature) {
+			verified = true
+			break
+		}
+	}
+
+	if !verified {
+		return nil, gserrors.Newf(nil, "invalid JWT signature, alg %s kid %s", header.Alg, header.Kid)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+
+	if err != nil {
+		return nil, gserrors.Newf(err, "malformed JWT payload")
+	}
+
+	var claims map[string]interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, gserrors.Newf(err, "malformed JWT payload")
+	}
+
+	if err := jwt.checkClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// maxNumericDate the NumericDate upper bound, 10000-01-01T00:00:00Z
+const maxNumericDate = 253402300800
+
+// numericClaim get NumericDate claim, returns false if not exists, the values
+// out of range are rejected
+func numericClaim(claims map[string]interface{}, name string) (time.Time, bool, error) {
+
+	value, ok := claims[name]
+
+	if !ok {
+		return time.Time{}, false, nil
+	}
+
+	number, ok := value.(json.Number)
+
+	if !ok {
+		return time.Time{}, false, gserrors.Newf(nil, "invalid JWT %s claim", name)
+	}
+
+	seconds, err := number.Float64()
+
+	if err != nil {
+		return time.Time{}, false, gserrors.Newf(err, "invalid JWT %s claim", name)
+	}
+
+	// NaN fails the range check too
+	if !(seconds >= 0 && seconds < maxNumericDate) {
+		return time.Time{}, false, gserrors.Newf(nil, "JWT %s claim %s out of range", name, number)
+	}
+
+	whole, fraction := math.Modf(seconds)
+
+	return time.Unix(int64(whole), int64(fraction*float64(time.Second))), true, nil
+}
+
+// checkClaims check exp, nbf, iat, iss and aud claims
+func (jwt *JWTAuth) checkClaims(claims map[string]interface{}) error {
+
+	now := time.Now()
+
+	leeway := jwt.config.Leeway
+
+	if exp, ok, err := numericClaim(claims, "exp"); err != nil {
+		return err
+	} else if ok && !now.Before(exp.Add(leeway)) {
+		return gserrors.Newf(nil, "JWT expired at %s", exp)
+	}
+
+	if nbf, ok, err := numericClaim(claims, "nbf"); err != nil {
+		return err
+	} else if ok && now.Add(leeway).Before(nbf) {
+		return gserrors.Newf(nil, "JWT not valid before %s", nbf)
+	}
+
+	if iat, ok, err := numericClaim(claims, "iat"); err != nil {
+		return err
+	} else if ok && now.Add(leeway).Before(iat) {
+		return gserrors.Newf(nil, "JWT issued in the future %s", iat)
+	}
+
+	if jwt.config.Issuer != "" {
+		if issuer, _ := claims["iss"].(string); issuer != jwt.config.Issuer {
+			return gserrors.Newf(nil, "unexpected JWT issuer %s", issuer)
+		}
+	}
+
+	if jwt.config.Audience != "" {
+
+		matched := false
+
+		switch audience := claims["aud"].(type) {
+		case string:
+			matched = audience == jwt.config.Audience
+		case []interface{}:
+			for _, item := range audience {
+				if item == jwt.config.Audience {
+					matched = true
+					break
+				}
+			}
+		}
+
+		if !matched {
+			return gserrors.Newf(nil, "unexpected JWT audience %v", claims["aud"])
+		}
+	}
+
+	return nil
+}
+
+// Name implement AuthProvider
+func (jwt *JWTAuth) Name() string {
+	return "jwt"
+}
+
+// Authenticate implement AuthProvider
+func (jwt *JWTAuth) Authenticate(context *Context) (*Principal, error) {
+
+	token, ok := bearerToken(context.Request())
+
+	if !ok {
+		return nil, nil
+	}
+
+	claims, err := jwt.Verify(token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	subject, ok := claims[jwt.config.SubjectClaim].(string)
+
+	if !ok || subject == "" {
+		return nil, gserrors.Newf(nil, "JWT missing string %s claim", jwt.config.SubjectClaim)
+	}
+
+	return &Principal{Subject: subject, Claims: claims}, nil
+}
+
+// Challenge implement AuthProvider
+func (jwt *JWTAuth) Challenge(err error) string {
+
+	challenge := "Bearer"
+
+	if jwt.config.Realm != "" {
+		challenge += ` realm="` + strings.Replace(jwt.config.Realm, `"`, `'`, -1) + `"`
+	}
+
+	if err != nil {
+		if jwt.config.Realm != "" {
+			challenge += ","
+		}
+
+		challenge += ` error="invalid_token"`
+	}
+
+	return challenge
+}
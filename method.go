@@ -1,5 +1,11 @@
 package gsweb
 
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
 // Get .
 type Get interface {
 	HandleGet(context *Context) error
@@ -20,6 +26,21 @@ type Delete interface {
 	HandleDelete(context *Context) error
 }
 
+// Head .
+type Head interface {
+	HandleHead(context *Context) error
+}
+
+// Options .
+type Options interface {
+	HandleOptions(context *Context) error
+}
+
+// Patch .
+type Patch interface {
+	HandlePatch(context *Context) error
+}
+
 // Unknown .
 type Unknown interface {
 	HandleUnknown(context *Context) error
@@ -28,6 +49,10 @@ type Unknown interface {
 // MethodHandler .
 type MethodHandler func(context *Context) error
 
+// Methods the method handlers indexed by http method name, can be passed to
+// URIHandler.Handle directly, e.g. Methods{"POST": Typed(createUser)}
+type Methods map[string]MethodHandler
+
 // MethodExtractor .
 type MethodExtractor func(interface{}) (func(context *Context) error, bool)
 
@@ -64,6 +89,30 @@ var methodExtractors = map[string]MethodExtractor{
 		return nil, false
 	},
 
+	"HEAD": func(handler interface{}) (func(context *Context) error, bool) {
+		if get, ok := handler.(Head); ok {
+			return get.HandleHead, true
+		}
+
+		return nil, false
+	},
+
+	"OPTIONS": func(handler interface{}) (func(context *Context) error, bool) {
+		if get, ok := handler.(Options); ok {
+			return get.HandleOptions, true
+		}
+
+		return nil, false
+	},
+
+	"PATCH": func(handler interface{}) (func(context *Context) error, bool) {
+		if get, ok := handler.(Patch); ok {
+			return get.HandlePatch, true
+		}
+
+		return nil, false
+	},
+
 	"UNKNOWN": func(handler interface{}) (func(context *Context) error, bool) {
 		if get, ok := handler.(Unknown); ok {
 			return get.HandleUnknown, true
@@ -73,20 +122,86 @@ var methodExtractors = map[string]MethodExtractor{
 	},
 }
 
-//ExtractMethods .
+//ExtractMethods extract method handlers, HEAD is served by the Get handler
+// with response body discarded if handler not implement Head interface
 func ExtractMethods(handler interface{}) map[string]MethodHandler {
 	handlers := make(map[string]MethodHandler)
 
+	if methods, ok := handler.(Methods); ok {
+		for k, v := range methods {
+			handlers[k] = v
+		}
+	}
+
 	for k, v := range methodExtractors {
 		if methodHandler, ok := v(handler); ok {
 			handlers[k] = methodHandler
 		}
 	}
 
+	if get, ok := handlers["GET"]; ok {
+		if _, ok := handlers["HEAD"]; !ok {
+			handlers["HEAD"] = headHandler(get)
+		}
+	}
+
 	return handlers
 }
 
+// headHandler serve HEAD request by Get handler with response body discarded
+func headHandler(get MethodHandler) MethodHandler {
+	return func(context *Context) error {
+		context.responseWriter.discardBody = true
+		return get(context)
+	}
+}
+
+// optionsHandler respond OPTIONS request with Allow header
+func optionsHandler(methods []string) MethodHandler {
+	return func(context *Context) error {
+		context.Response().Header().Set("Allow", strings.Join(methods, ", "))
+		context.Response().WriteHeader(http.StatusNoContent)
+		return context.Success()
+	}
+}
+
 // HTTPMethod register customer http method
 func HTTPMethod(name string, extractor MethodExtractor) {
 	methodExtractors[name] = extractor
 }
+
+// allowedMethods get the sorted method names of handlers, the UNKNOWN method
+// is excluded and the automatic OPTIONS method is included
+func allowedMethods(handlers map[string]MethodHandler) []string {
+	methods := []string{"OPTIONS"}
+
+	for name := range handlers {
+		if name != "UNKNOWN" && name != "OPTIONS" {
+			methods = append(methods, name)
+		}
+	}
+
+	sort.Strings(methods)
+
+	return methods
+}
+
+// mergeMethods append the methods not exists in methods
+func mergeMethods(methods []string, others []string) []string {
+	for _, other := range others {
+		found := false
+
+		for _, method := range methods {
+			if method == other {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			methods = append(methods, other)
+		}
+	}
+
+	return methods
+}
@@ -0,0 +1,100 @@
+package gsweb
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+type methodTestHandler struct{}
+
+func (methodTestHandler) HandleGet(context *Context) error {
+	context.Response().Header().Set("X-Handler", "get")
+	return context.Text(200, "get")
+}
+
+func (methodTestHandler) HandlePatch(context *Context) error {
+	return context.Text(200, "patch")
+}
+
+type methodTestHeadHandler struct {
+	methodTestHandler
+}
+
+func (methodTestHeadHandler) HandleHead(context *Context) error {
+	context.Response().Header().Set("X-Handler", "head")
+	context.Response().WriteHeader(204)
+	return context.Success()
+}
+
+type methodTestOptionsHandler struct{}
+
+func (methodTestOptionsHandler) HandleOptions(context *Context) error {
+	return context.Text(200, "options")
+}
+
+func TestMethodDispatch(t *testing.T) {
+
+	router := newRouter()
+
+	uri := NewURIHandler()
+	uri.Handle("/auto", methodTestHandler{})
+	uri.Handle("/head", methodTestHeadHandler{})
+	uri.Handle("/options", methodTestOptionsHandler{})
+	uri.Handle("/methods", Methods{"GET": methodTestHandler{}.HandleGet})
+	uri.HandleMethod("GET", "/single", methodTestHandler{}.HandleGet)
+	uri.HandleMethod("POST", "/single", methodTestHandler{}.HandlePatch)
+	router.ChainHandle("uri", uri)
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		code    int
+		body    string
+		handler string // X-Handler header
+		allow   string
+	}{
+		{"get", "GET", "/auto", 200, "get", "get", ""},
+		{"automatic head", "HEAD", "/auto", 200, "", "get", ""},
+		{"patch", "PATCH", "/auto", 200, "patch", "", ""},
+		{"automatic options", "OPTIONS", "/auto", 204, "", "", "GET, HEAD, OPTIONS, PATCH"},
+		{"method not allowed", "PUT", "/auto", 405, "", "", "GET, HEAD, OPTIONS, PATCH"},
+		{"explicit head", "HEAD", "/head", 204, "", "head", ""},
+		{"explicit options", "OPTIONS", "/options", 200, "options", "", ""},
+		{"methods map head", "HEAD", "/methods", 200, "", "get", ""},
+		{"handle method head", "HEAD", "/single", 200, "", "get", ""},
+		{"handle method options", "OPTIONS", "/single", 204, "", "", "GET, HEAD, OPTIONS, POST"},
+	}
+
+	for _, test := range tests {
+
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, nil))
+
+		if recorder.Code != test.code {
+			t.Errorf("%s code got %d, expect %d", test.name, recorder.Code, test.code)
+		}
+
+		if test.code != 405 && recorder.Body.String() != test.body {
+			t.Errorf("%s body got %q, expect %q", test.name, recorder.Body.String(), test.body)
+		}
+
+		if got := recorder.Header().Get("X-Handler"); got != test.handler {
+			t.Errorf("%s handler got %q, expect %q", test.name, got, test.handler)
+		}
+
+		if got := recorder.Header().Get("Allow"); got != test.allow {
+			t.Errorf("%s allow got %q, expect %q", test.name, got, test.allow)
+		}
+	}
+
+	// the automatic head response keeps the get response's headers
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("HEAD", "/auto", nil))
+
+	if got := recorder.Header().Get("Content-Length"); got != "3" {
+		t.Errorf("head content length got %q", got)
+	}
+}
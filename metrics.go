@@ -0,0 +1,314 @@
+package gsweb
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gsdocker/gslogger"
+)
+
+// DefaultBuckets the default request latency histogram buckets in seconds
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metricSeries the metric values of one label set
+type metricSeries struct {
+	labels  []string // label values
+	value   float64  // counter or gauge value
+	buckets []uint64 // histogram bucket counts, not cumulative
+	count   uint64   // histogram observation count
+	sum     float64  // histogram observation sum
+}
+
+// metricFamily the metric with name, type and labels
+type metricFamily struct {
+	name    string                   // metric name
+	help    string                   // metric help text
+	kind    string                   // counter, gauge or histogram
+	labels  []string                 // label names
+	buckets []float64                // histogram bucket upper bounds
+	series  map[string]*metricSeries // series indexed by joined label values
+}
+
+func newMetricFamily(name, help, kind string, buckets []float64, labels ...string) *metricFamily {
+	return &metricFamily{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*metricSeries),
+	}
+}
+
+// get get or create series by label values, must be called with Metrics.mutex held
+func (family *metricFamily) get(labels ...string) *metricSeries {
+
+	key := strings.Join(labels, "\xff")
+
+	series, ok := family.series[key]
+
+	if !ok {
+		series = &metricSeries{labels: labels}
+
+		if family.kind == "histogram" {
+			series.buckets = make([]uint64, len(family.buckets))
+		}
+
+		family.series[key] = series
+	}
+
+	return series
+}
+
+func (family *metricFamily) observe(value float64, labels ...string) {
+
+	series := family.get(labels...)
+
+	series.count++
+	series.sum += value
+
+	if i := sort.SearchFloat64s(family.buckets, value); i < len(family.buckets) {
+		series.buckets[i]++
+	}
+}
+
+// MetricsConfig the metrics config
+type MetricsConfig struct {
+	Namespace string    // metric name prefix, default gsweb
+	Buckets   []float64 // request latency histogram buckets in seconds, default DefaultBuckets
+}
+
+// Metrics the request metrics registry recorded by Router and exposed in
+// prometheus text exposition format
+type Metrics struct {
+	mutex        sync.Mutex      // metrics guard
+	requests     *metricFamily   // request counter
+	duration     *metricFamily   // request latency histogram
+	responseSize *metricFamily   // response size counter
+	inFlight     *metricFamily   // in-flight requests gauge
+	files        *metricFamily   // FileHandler hit/miss counter
+	families     []*metricFamily // families ordered by exposition
+}
+
+// NewMetrics create metrics registry, set it to router by Router.SetMetrics
+func NewMetrics(config MetricsConfig) *Metrics {
+
+	if config.Namespace == "" {
+		config.Namespace = "gsweb"
+	}
+
+	if len(config.Buckets) == 0 {
+		config.Buckets = DefaultBuckets
+	}
+
+	buckets := append([]float64(nil), config.Buckets...)
+
+	sort.Float64s(buckets)
+
+	prefix := config.Namespace + "_"
+
+	metrics := &Metrics{
+		requests: newMetricFamily(
+			prefix+"http_requests_total", "Total handled http requests.", "counter", nil,
+			"handler", "method", "route", "status"),
+		duration: newMetricFamily(
+			prefix+"http_request_duration_seconds", "Http request latency in seconds.", "histogram", buckets,
+			"handler", "method", "route", "status"),
+		responseSize: newMetricFamily(
+			prefix+"http_response_size_bytes_total", "Total written response body bytes.", "counter", nil,
+			"handler", "method", "route", "status"),
+		inFlight: newMetricFamily(
+			prefix+"http_requests_in_flight", "Http requests being processed.", "gauge", nil,
+			"method"),
+		files: newMetricFamily(
+			prefix+"file_requests_total", "FileHandler requests by result.", "counter", nil,
+			"prefix", "result"),
+	}
+
+	metrics.families = []*metricFamily{
+		metrics.requests,
+		metrics.duration,
+		metrics.responseSize,
+		metrics.inFlight,
+		metrics.files,
+	}
+
+	return metrics
+}
+
+// metricMethod normalize request method label, the non-standard methods are
+// counted as OTHER to bound the label cardinality
+func metricMethod(method string) string {
+	switch method {
+	case "GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "CONNECT", "TRACE":
+		return method
+	}
+
+	return "OTHER"
+}
+
+// statusClass get status class label, e.g. 2xx
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "unknown"
+	}
+
+	return strconv.Itoa(status/100) + "xx"
+}
+
+// begin record request started
+func (metrics *Metrics) begin(context *Context) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	metrics.inFlight.get(metricMethod(context.RequestMethod())).value++
+}
+
+// end record request completed
+func (metrics *Metrics) end(context *Context) {
+
+	method := metricMethod(context.RequestMethod())
+
+	handler := context.HandlerName()
+
+	if handler == "" {
+		handler = "unhandled"
+	}
+
+	labels := []string{handler, method, context.Route(), statusClass(context.Status())}
+
+	latency := time.Since(context.Started()).Seconds()
+
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	metrics.inFlight.get(method).value--
+	metrics.requests.get(labels...).value++
+	metrics.responseSize.get(labels...).value += float64(context.Size())
+	metrics.duration.observe(latency, labels...)
+}
+
+// fileRequest record FileHandler request result, hit or miss
+func (metrics *Metrics) fileRequest(prefix string, hit bool) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	result := "miss"
+
+	if hit {
+		result = "hit"
+	}
+
+	metrics.files.get(prefix, result).value++
+}
+
+func formatMetricValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(names []string, values []string, extra ...string) string {
+
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+
+	var pairs []string
+
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+labelEscaper.Replace(values[i])+`"`)
+	}
+
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+labelEscaper.Replace(extra[i+1])+`"`)
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// Expose format metrics in prometheus text exposition format
+func (metrics *Metrics) Expose() []byte {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	var buff bytes.Buffer
+
+	for _, family := range metrics.families {
+
+		fmt.Fprintf(&buff, "# HELP %s %s\n# TYPE %s %s\n", family.name, family.help, family.name, family.kind)
+
+		keys := make([]string, 0, len(family.series))
+
+		for key := range family.series {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+
+			series := family.series[key]
+
+			if family.kind != "histogram" {
+				fmt.Fprintf(&buff, "%s%s %s\n", family.name, formatLabels(family.labels, series.labels), formatMetricValue(series.value))
+				continue
+			}
+
+			var cumulative uint64
+
+			for i, bound := range family.buckets {
+				cumulative += series.buckets[i]
+				fmt.Fprintf(&buff, "%s_bucket%s %d\n", family.name, formatLabels(family.labels, series.labels, "le", formatMetricValue(bound)), cumulative)
+			}
+
+			fmt.Fprintf(&buff, "%s_bucket%s %d\n", family.name, formatLabels(family.labels, series.labels, "le", "+Inf"), series.count)
+			fmt.Fprintf(&buff, "%s_sum%s %s\n", family.name, formatLabels(family.labels, series.labels), formatMetricValue(series.sum))
+			fmt.Fprintf(&buff, "%s_count%s %d\n", family.name, formatLabels(family.labels, series.labels), series.count)
+		}
+	}
+
+	return buff.Bytes()
+}
+
+// MetricsHandler the chain node serving metrics in prometheus text
+// exposition format
+type MetricsHandler struct {
+	gslogger.Log          // Mixin log APIs
+	path         string   // the mounted uri
+	metrics      *Metrics // the exposed metrics
+}
+
+// NewMetricsHandler create metrics handler mounted on path, e.g. /metrics
+func NewMetricsHandler(path string, metrics *Metrics) *MetricsHandler {
+	return &MetricsHandler{
+		Log:     gslogger.Get("metrics"),
+		path:    path,
+		metrics: metrics,
+	}
+}
+
+// HandleGet implement Get interface
+func (handler *MetricsHandler) HandleGet(context *Context) error {
+
+	if context.RequestURI() != handler.path {
+		return context.Forward()
+	}
+
+	context.Response().Header().Set("Cache-Control", "no-store")
+
+	return context.write(200, "text/plain; version=0.0.4; charset=utf-8", handler.metrics.Expose())
+}
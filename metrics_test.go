@@ -0,0 +1,97 @@
+package gsweb
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type metricsTestHandler struct{}
+
+func (metricsTestHandler) HandleGet(context *Context) error {
+	return context.Text(200, "user %s", context.Param("id"))
+}
+
+func TestMetricsExposition(t *testing.T) {
+
+	metrics := NewMetrics(MetricsConfig{Namespace: "test", Buckets: []float64{1, 0.1}})
+
+	metrics.duration.observe(0.05, "uri", "GET", "/a", "2xx")
+	metrics.duration.observe(0.5, "uri", "GET", "/a", "2xx")
+	metrics.duration.observe(2, "uri", "GET", "/a", "2xx")
+	metrics.requests.get("uri", "GET", "/a", "2xx").value = 3
+	metrics.requests.get("uri", "GET", `/q"\`+"\n", "4xx").value = 1
+	metrics.fileRequest("/static", true)
+
+	expect := `# HELP test_http_requests_total Total handled http requests.
+# TYPE test_http_requests_total counter
+test_http_requests_total{handler="uri",method="GET",route="/a",status="2xx"} 3
+test_http_requests_total{handler="uri",method="GET",route="/q\"\\\n",status="4xx"} 1
+# HELP test_http_request_duration_seconds Http request latency in seconds.
+# TYPE test_http_request_duration_seconds histogram
+test_http_request_duration_seconds_bucket{handler="uri",method="GET",route="/a",status="2xx",le="0.1"} 1
+test_http_request_duration_seconds_bucket{handler="uri",method="GET",route="/a",status="2xx",le="1"} 2
+test_http_request_duration_seconds_bucket{handler="uri",method="GET",route="/a",status="2xx",le="+Inf"} 3
+test_http_request_duration_seconds_sum{handler="uri",method="GET",route="/a",status="2xx"} 2.55
+test_http_request_duration_seconds_count{handler="uri",method="GET",route="/a",status="2xx"} 3
+# HELP test_http_response_size_bytes_total Total written response body bytes.
+# TYPE test_http_response_size_bytes_total counter
+# HELP test_http_requests_in_flight Http requests being processed.
+# TYPE test_http_requests_in_flight gauge
+# HELP test_file_requests_total FileHandler requests by result.
+# TYPE test_file_requests_total counter
+test_file_requests_total{prefix="/static",result="hit"} 1
+`
+
+	if got := string(metrics.Expose()); got != expect {
+		t.Errorf("exposition got\n%s\nexpect\n%s", got, expect)
+	}
+}
+
+func TestMetricsRouter(t *testing.T) {
+
+	metrics := NewMetrics(MetricsConfig{})
+
+	router := newRouter()
+	router.SetMetrics(metrics)
+	router.ChainHandle("metrics", NewMetricsHandler("/metrics", metrics))
+
+	uri := NewURIHandler()
+	uri.Handle("/users/:id", metricsTestHandler{})
+	router.ChainHandle("uri", uri)
+
+	for _, request := range []struct{ method, path string }{
+		{"GET", "/users/1"},
+		{"GET", "/users/2"},
+		{"PROPFIND", "/users/1"},
+		{"GET", "/missing"},
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(request.method, request.path, nil))
+	}
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	if got := recorder.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("content type got %s", got)
+	}
+
+	body := recorder.Body.String()
+
+	for _, expect := range []string{
+		`gsweb_http_requests_total{handler="uri",method="GET",route="/users/:id",status="2xx"} 2`,
+		`gsweb_http_requests_total{handler="unhandled",method="OTHER",route="",status="4xx"} 1`,
+		`gsweb_http_requests_total{handler="unhandled",method="GET",route="",status="4xx"} 1`,
+		`gsweb_http_response_size_bytes_total{handler="uri",method="GET",route="/users/:id",status="2xx"} 12`,
+		`gsweb_http_request_duration_seconds_count{handler="uri",method="GET",route="/users/:id",status="2xx"} 2`,
+		`gsweb_http_request_duration_seconds_bucket{handler="uri",method="GET",route="/users/:id",status="2xx",le="+Inf"} 2`,
+		`gsweb_http_requests_in_flight{method="OTHER"} 0`,
+		// the scrape itself is in flight
+		`gsweb_http_requests_in_flight{method="GET"} 1`,
+	} {
+		if !strings.Contains(body, expect+"\n") {
+			t.Errorf("exposition missing %s\n%s", expect, body)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package gsweb
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Middleware the declarative middleware with before/after phases.
+//
+// Middlewares are chain nodes, so they run in the register order relative to
+// every other chain node: the Before hooks are called in register order, the
+// After hooks are called in reverse order after the rest of chain returned.
+//
+// Before returning error breaks the chain processing, Before writing response
+// or calling context.Success skips the rest of chain but still calls After.
+// After receives the error returned by the rest of chain and returns the
+// error passed back to the previous chain node, returning nil recovers the
+// failure, non HTTPError errors are rendered as 500 Internal Server Error.
+type Middleware struct {
+	Before func(context *Context) error            // before phase hook, may be nil
+	After  func(context *Context, err error) error // after phase hook, may be nil
+}
+
+// middlewareHandler the chain node adapter of Middleware
+type middlewareHandler struct {
+	prefix     string     // the group prefix, empty for all requests
+	middleware Middleware // middleware
+}
+
+// HandleUnknown implement Unknown interface
+func (handler *middlewareHandler) HandleUnknown(context *Context) error {
+
+	if !matchPrefix(context.RequestURI(), handler.prefix) {
+		return context.Forward()
+	}
+
+	if handler.middleware.Before != nil {
+		if err := handler.middleware.Before(context); err != nil {
+			return context.Failed(err, "middleware before phase error : %s", err)
+		}
+	}
+
+	var err error
+
+	if context.Handled() {
+		err = context.Success()
+	} else {
+		err = context.Forward()
+	}
+
+	if handler.middleware.After != nil {
+
+		failed := err
+
+		err = handler.middleware.After(context, err)
+
+		// the error returned by After replaces the chain's failure
+		var httpError *HTTPError
+
+		switch {
+		case err == nil:
+			if failed != nil {
+				context.failure = nil
+				context.succeeded = true
+			}
+		case errors.As(err, &httpError):
+			context.failure = httpError
+		case failed != nil && errors.Is(err, failed):
+			// passed through, keep the chain's failure
+		default:
+			context.failure = NewHTTPError(http.StatusInternalServerError, err, "")
+		}
+	}
+
+	return err
+}
+
+// matchPrefix check if the path is the prefix itself or under the prefix
+func matchPrefix(path string, prefix string) bool {
+	if prefix == "" {
+		return true
+	}
+
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// Group the route group scoping middlewares under uri prefix
+type Group struct {
+	router *Router // router belongs
+	prefix string  // uri prefix
+}
+
+// Group create route group, the middlewares registered by group only apply
+// to requests under the uri prefix
+func (router *Router) Group(prefix string) *Group {
+	return &Group{
+		router: router,
+		prefix: strings.TrimSuffix(prefix, "/"),
+	}
+}
+
+// Use register middleware as chain node named by parameter name
+func (router *Router) Use(name string, middleware Middleware) {
+	router.ChainHandle(name, &middlewareHandler{middleware: middleware})
+}
+
+// Group create sub group under current group's prefix
+func (group *Group) Group(prefix string) *Group {
+	return group.router.Group(group.prefix + "/" + strings.Trim(prefix, "/"))
+}
+
+// Prefix get the group's uri prefix
+func (group *Group) Prefix() string {
+	return group.prefix
+}
+
+// Use register middleware only applies to the group's requests as chain node
+// named by parameter name
+func (group *Group) Use(name string, middleware Middleware) {
+	group.router.ChainHandle(name, &middlewareHandler{prefix: group.prefix, middleware: middleware})
+}
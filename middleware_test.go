@@ -0,0 +1,172 @@
+package gsweb
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type middlewareTestHandler struct {
+	trace *[]string // the shared call trace
+}
+
+func (handler middlewareTestHandler) HandleGet(context *Context) error {
+
+	*handler.trace = append(*handler.trace, "h")
+
+	if strings.HasSuffix(context.RequestURI(), "/fail") {
+		return NewHTTPError(http.StatusForbidden, nil, "")
+	}
+
+	return context.Text(200, "ok")
+}
+
+// traceMiddleware create middleware appending name> and <name to trace
+func traceMiddleware(trace *[]string, name string) Middleware {
+	return Middleware{
+		Before: func(context *Context) error {
+			*trace = append(*trace, name+">")
+
+			if context.Request().Header.Get("X-Stop") == name {
+				return context.Text(200, "stopped by %s", name)
+			}
+
+			if context.Request().Header.Get("X-Reject") == name {
+				return NewHTTPError(http.StatusUnauthorized, nil, "")
+			}
+
+			return nil
+		},
+		After: func(context *Context, err error) error {
+			*trace = append(*trace, "<"+name)
+			return err
+		},
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+
+	var trace []string
+
+	router := newRouter()
+	router.Use("a", traceMiddleware(&trace, "a"))
+	router.Use("b", traceMiddleware(&trace, "b"))
+
+	api := router.Group("/api/")
+	api.Use("api", traceMiddleware(&trace, "api"))
+
+	v1 := api.Group("v1")
+	v1.Use("v1", traceMiddleware(&trace, "v1"))
+
+	if v1.Prefix() != "/api/v1" {
+		t.Errorf("nested group prefix got %s", v1.Prefix())
+	}
+
+	uri := NewURIHandler()
+
+	for _, path := range []string{"/x", "/api", "/api/x", "/api/v1/x", "/apix", "/api/fail"} {
+		uri.Handle(path, middlewareTestHandler{trace: &trace})
+	}
+
+	router.ChainHandle("uri", uri)
+
+	tests := []struct {
+		name   string
+		path   string
+		header string // X-Stop or X-Reject header
+		value  string // the middleware name stopping or rejecting the request
+		trace  string
+		code   int
+	}{
+		{"global", "/x", "", "", "a> b> h <b <a", 200},
+		{"group prefix itself", "/api", "", "", "a> b> api> h <api <b <a", 200},
+		{"group", "/api/x", "", "", "a> b> api> h <api <b <a", 200},
+		{"nested group", "/api/v1/x", "", "", "a> b> api> v1> h <v1 <api <b <a", 200},
+		{"group prefix boundary", "/apix", "", "", "a> b> h <b <a", 200},
+		{"handler error", "/api/fail", "", "", "a> b> api> h <api <b <a", 403},
+		{"before writes response", "/api/x", "X-Stop", "b", "a> b> <b <a", 200},
+		{"before error", "/api/x", "X-Reject", "api", "a> b> api> <b <a", 401},
+	}
+
+	for _, test := range tests {
+
+		trace = nil
+
+		request := httptest.NewRequest("GET", test.path, nil)
+
+		if test.header != "" {
+			request.Header.Set(test.header, test.value)
+		}
+
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, request)
+
+		if got := strings.Join(trace, " "); got != test.trace {
+			t.Errorf("%s trace got %q, expect %q", test.name, got, test.trace)
+		}
+
+		if recorder.Code != test.code {
+			t.Errorf("%s code got %d, expect %d", test.name, recorder.Code, test.code)
+		}
+	}
+}
+
+func TestMiddlewareAfterError(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		after func(context *Context, err error) error
+		code  int
+	}{
+		{
+			"pass through",
+			func(context *Context, err error) error { return err },
+			http.StatusForbidden,
+		},
+		{
+			"recover",
+			func(context *Context, err error) error { return context.Text(200, "recovered") },
+			http.StatusOK,
+		},
+		{
+			"recover without response",
+			func(context *Context, err error) error { return nil },
+			http.StatusOK,
+		},
+		{
+			"replace with http error",
+			func(context *Context, err error) error {
+				return NewHTTPError(http.StatusTeapot, err, "")
+			},
+			http.StatusTeapot,
+		},
+		{
+			"replace with plain error",
+			func(context *Context, err error) error { return errors.New("after error") },
+			http.StatusInternalServerError,
+		},
+	}
+
+	for _, test := range tests {
+
+		var trace []string
+
+		router := newRouter()
+		router.Use("after", Middleware{After: test.after})
+
+		uri := NewURIHandler()
+		uri.Handle("/fail", middlewareTestHandler{trace: &trace})
+		router.ChainHandle("uri", uri)
+
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", "/fail", nil))
+
+		if recorder.Code != test.code {
+			t.Errorf("%s code got %d, expect %d", test.name, recorder.Code, test.code)
+		}
+	}
+}
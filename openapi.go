@@ -0,0 +1,630 @@
+package gsweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gsdocker/gserrors"
+)
+
+// Operation the api document attached to route method by Route.Describe
+type Operation struct {
+	Summary     string      // operation summary
+	Description string      // operation description
+	Tags        []string    // operation tags
+	OperationID string      // unique operation id
+	Deprecated  bool        // deprecated flag
+	Request     interface{} // request struct value, fields tagged by path/query are parameters, the others are json body
+	Response    interface{} // response body value
+	Status      int         // success response status code, default 200
+}
+
+// OpenAPIInfo the OpenAPI document info object
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// schemaBuilder build json schema from go types
+type schemaBuilder struct {
+	building map[reflect.Type]bool // types under building, used to stop recursion
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+func (builder *schemaBuilder) schema(t reflect.Type) map[string]interface{} {
+
+	for t.Kind() == reflect.Ptr {
+		if t == fileHeaderType {
+			return map[string]interface{}{"type": "string", "format": "binary"}
+		}
+
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == durationType:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case t.Implements(textUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return map[string]interface{}{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32:
+		return map[string]interface{}{"type": "number", "format": "float"}
+	case reflect.Float64:
+		return map[string]interface{}{"type": "number", "format": "double"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+
+		return map[string]interface{}{"type": "array", "items": builder.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": builder.schema(t.Elem())}
+	case reflect.Struct:
+		return builder.object(t, func(field reflect.StructField) bool { return true })
+	}
+
+	return map[string]interface{}{}
+}
+
+// object build object schema of struct fields accepted by filter
+func (builder *schemaBuilder) object(t reflect.Type, filter func(field reflect.StructField) bool) map[string]interface{} {
+
+	if builder.building[t] {
+		return map[string]interface{}{"type": "object"}
+	}
+
+	builder.building[t] = true
+	defer delete(builder.building, t)
+
+	properties := make(map[string]interface{})
+
+	var required []string
+
+	builder.fields(t, filter, properties, &required)
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+func (builder *schemaBuilder) fields(t reflect.Type, filter func(field reflect.StructField) bool, properties map[string]interface{}, required *[]string) {
+
+	for i := 0; i < t.NumField(); i++ {
+
+		field := t.Field(i)
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "" {
+			builder.fields(field.Type, filter, properties, required)
+			continue
+		}
+
+		if field.PkgPath != "" || !filter(field) {
+			continue
+		}
+
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		schema := builder.schema(field.Type)
+
+		if applyValidateRules(schema, field.Tag.Get("validate")) {
+			*required = append(*required, name)
+		}
+
+		properties[name] = schema
+	}
+}
+
+// applyValidateRules add validate tag constraints to schema, returns true if the field is required
+func applyValidateRules(schema map[string]interface{}, rules string) bool {
+
+	required := false
+
+	for _, rule := range splitRules(rules) {
+
+		name, arg := rule, ""
+
+		if i := strings.IndexByte(rule, '='); i != -1 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+
+		switch name {
+		case "required":
+			required = true
+
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+
+			if err != nil {
+				continue
+			}
+
+			keyword := map[string]string{"integer": "imum", "number": "imum", "string": "Length", "array": "Items", "object": "Properties"}[schemaType(schema)]
+
+			if keyword != "" {
+				schema[name+keyword] = limit
+			}
+
+		case "enum":
+			var values []interface{}
+
+			for _, option := range strings.Split(arg, "|") {
+				values = append(values, option)
+			}
+
+			if schemaType(schema) == "array" {
+				if items, ok := schema["items"].(map[string]interface{}); ok {
+					items["enum"] = values
+				}
+			} else {
+				schema["enum"] = values
+			}
+
+		case "regex":
+			schema["pattern"] = arg
+		}
+	}
+
+	return required
+}
+
+func schemaType(schema map[string]interface{}) string {
+	name, _ := schema["type"].(string)
+	return name
+}
+
+// openAPIPath convert uri pattern to OpenAPI path template and path parameters
+func openAPIPath(pattern string) (string, []map[string]interface{}) {
+
+	var path strings.Builder
+
+	var params []map[string]interface{}
+
+	for _, token := range parseURIPattern(pattern) {
+
+		if token.static != "" {
+			path.WriteString(token.static)
+			continue
+		}
+
+		path.WriteString("{" + token.name + "}")
+
+		schema := map[string]interface{}{"type": "string"}
+
+		if token.constraint != "" {
+			schema["pattern"] = "^(?:" + token.constraint + ")$"
+		}
+
+		params = append(params, map[string]interface{}{
+			"name":     token.name,
+			"in":       "path",
+			"required": true,
+			"schema":   schema,
+		})
+	}
+
+	return path.String(), params
+}
+
+// parameterKey get the operation parameter key, parameters are unique by
+// location and name, e.g. path id and query id are different parameters
+func parameterKey(in string, name string) string {
+	return in + ":" + name
+}
+
+// operationDocument build OpenAPI operation object
+func (builder *schemaBuilder) operationDocument(method string, pattern string, operation *Operation) map[string]interface{} {
+
+	_, pathParams := openAPIPath(pattern)
+
+	document := map[string]interface{}{}
+
+	params := make(map[string]map[string]interface{})
+
+	for _, param := range pathParams {
+		params[parameterKey("path", param["name"].(string))] = param
+	}
+
+	status := http.StatusOK
+
+	var response interface{}
+
+	if operation != nil {
+
+		if operation.Summary != "" {
+			document["summary"] = operation.Summary
+		}
+
+		if operation.Description != "" {
+			document["description"] = operation.Description
+		}
+
+		if len(operation.Tags) > 0 {
+			document["tags"] = operation.Tags
+		}
+
+		if operation.OperationID != "" {
+			document["operationId"] = operation.OperationID
+		}
+
+		if operation.Deprecated {
+			document["deprecated"] = true
+		}
+
+		if operation.Status != 0 {
+			status = operation.Status
+		}
+
+		response = operation.Response
+
+		if operation.Request != nil {
+			builder.request(method, reflect.TypeOf(operation.Request), document, params)
+		}
+	}
+
+	if len(params) > 0 {
+		var keys []string
+
+		for key := range params {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		var list []interface{}
+
+		for _, key := range keys {
+			list = append(list, params[key])
+		}
+
+		document["parameters"] = list
+	}
+
+	success := map[string]interface{}{"description": http.StatusText(status)}
+
+	if response != nil && status != http.StatusNoContent {
+		success["content"] = map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": builder.schema(reflect.TypeOf(response))},
+		}
+	}
+
+	document["responses"] = map[string]interface{}{
+		strconv.Itoa(status): success,
+		"default": map[string]interface{}{
+			"description": "error",
+			"content": map[string]interface{}{
+				"application/problem+json": map[string]interface{}{"schema": problemSchema},
+			},
+		},
+	}
+
+	return document
+}
+
+var problemSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"type":     map[string]interface{}{"type": "string"},
+		"title":    map[string]interface{}{"type": "string"},
+		"status":   map[string]interface{}{"type": "integer"},
+		"detail":   map[string]interface{}{"type": "string"},
+		"instance": map[string]interface{}{"type": "string"},
+		"invalid-params": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"name":   map[string]interface{}{"type": "string"},
+					"reason": map[string]interface{}{"type": "string"},
+				},
+			},
+		},
+	},
+}
+
+// request add request struct's parameters and body into operation document
+func (builder *schemaBuilder) request(method string, t reflect.Type, document map[string]interface{}, params map[string]map[string]interface{}) {
+
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() != reflect.Struct {
+		if method != "GET" && method != "HEAD" && method != "DELETE" {
+			document["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": builder.schema(t)},
+				},
+			}
+		}
+
+		return
+	}
+
+	isParam := func(field reflect.StructField) bool {
+		return field.Tag.Get("path") != "" || field.Tag.Get("query") != ""
+	}
+
+	var collect func(t reflect.Type)
+
+	collect = func(t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+
+			field := t.Field(i)
+
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				collect(field.Type)
+				continue
+			}
+
+			for _, in := range []string{"path", "query"} {
+
+				name := strings.Split(field.Tag.Get(in), ",")[0]
+
+				if name == "" || name == "-" {
+					continue
+				}
+
+				schema := builder.schema(field.Type)
+
+				required := applyValidateRules(schema, field.Tag.Get("validate"))
+
+				param := map[string]interface{}{
+					"name":   name,
+					"in":     in,
+					"schema": schema,
+				}
+
+				if in == "path" || required {
+					param["required"] = true
+				}
+
+				if in == "query" && schemaType(schema) == "array" {
+					param["explode"] = true
+				}
+
+				// keep the route pattern's regex constraint for string parameters
+				if origin, ok := params[parameterKey(in, name)]; ok && schemaType(schema) == "string" && schema["pattern"] == nil {
+					if pattern, ok := origin["schema"].(map[string]interface{})["pattern"]; ok {
+						schema["pattern"] = pattern
+					}
+				}
+
+				params[parameterKey(in, name)] = param
+			}
+		}
+	}
+
+	collect(t)
+
+	if method == "GET" || method == "HEAD" || method == "DELETE" {
+		return
+	}
+
+	body := builder.object(t, func(field reflect.StructField) bool { return !isParam(field) })
+
+	if properties, ok := body["properties"].(map[string]interface{}); ok && len(properties) > 0 {
+		document["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": body},
+			},
+		}
+	}
+}
+
+// documentedMethods the route methods emitted into OpenAPI document, the
+// automatic HEAD/OPTIONS and UNKNOWN methods are skipped unless described
+func documentedMethods(route RouteInfo) []string {
+	var methods []string
+
+	for _, method := range route.Methods {
+		if _, ok := route.Operations[method]; ok {
+			methods = append(methods, method)
+			continue
+		}
+
+		if method != "HEAD" && method != "OPTIONS" && method != "UNKNOWN" {
+			methods = append(methods, method)
+		}
+	}
+
+	return methods
+}
+
+// OpenAPI generate OpenAPI 3 document from the URIHandler routes registered
+// in router's handle chain, the result can be encoded by encoding/json
+func (router *Router) OpenAPI(info OpenAPIInfo) map[string]interface{} {
+
+	builder := &schemaBuilder{building: make(map[reflect.Type]bool)}
+
+	paths := make(map[string]interface{})
+
+	for _, handler := range router.chain() {
+
+		uri, ok := handler.target.(*URIHandler)
+
+		if !ok {
+			continue
+		}
+
+		for _, route := range uri.Routes() {
+
+			path, _ := openAPIPath(route.Pattern)
+
+			item, ok := paths[path].(map[string]interface{})
+
+			if !ok {
+				item = make(map[string]interface{})
+				paths[path] = item
+			}
+
+			for _, method := range documentedMethods(route) {
+				item[strings.ToLower(method)] = builder.operationDocument(method, route.Pattern, route.Operations[method])
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    info,
+		"paths":   paths,
+	}
+}
+
+// EncodeYAML encode the OpenAPI document built by Router.OpenAPI as YAML,
+// the document is round tripped through encoding/json so map keys are sorted
+// and struct values are emitted by their json tags
+func EncodeYAML(document interface{}) ([]byte, error) {
+
+	content, err := json.Marshal(document)
+
+	if err != nil {
+		return nil, gserrors.Newf(err, "encode openapi document error")
+	}
+
+	var value interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+
+	decoder.UseNumber()
+
+	if err := decoder.Decode(&value); err != nil {
+		return nil, gserrors.Newf(err, "decode openapi document error")
+	}
+
+	var buff bytes.Buffer
+
+	writeYAML(&buff, value, 0)
+
+	return buff.Bytes(), nil
+}
+
+// writeYAML write value as yaml block at indent level
+func writeYAML(buff *bytes.Buffer, value interface{}, indent int) {
+
+	prefix := strings.Repeat("  ", indent)
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+
+		for key := range value {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			buff.WriteString(prefix + yamlScalar(key) + ":")
+			writeYAMLChild(buff, value[key], indent)
+		}
+
+	case []interface{}:
+		for _, item := range value {
+			buff.WriteString(prefix + "-")
+			writeYAMLChild(buff, item, indent)
+		}
+	}
+}
+
+// writeYAMLChild write the value of mapping key or sequence item
+func writeYAMLChild(buff *bytes.Buffer, value interface{}, indent int) {
+
+	switch child := value.(type) {
+	case map[string]interface{}:
+		if len(child) == 0 {
+			buff.WriteString(" {}\n")
+			return
+		}
+
+		buff.WriteString("\n")
+		writeYAML(buff, child, indent+1)
+
+	case []interface{}:
+		if len(child) == 0 {
+			buff.WriteString(" []\n")
+			return
+		}
+
+		buff.WriteString("\n")
+		writeYAML(buff, child, indent+1)
+
+	default:
+		buff.WriteString(" " + yamlScalar(child) + "\n")
+	}
+}
+
+// yamlScalar format scalar value, strings are quoted when they may be read
+// as other types or contain yaml indicators
+func yamlScalar(value interface{}) string {
+
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(value)
+	case json.Number:
+		return value.String()
+	case string:
+		if value == "" || strings.ContainsAny(value, ":#{}[],&*!|>'\"%@`\n\t\\") ||
+			strings.TrimSpace(value) != value || strings.ContainsAny(value[:1], "-?") ||
+			yamlReserved(value) {
+			content, _ := json.Marshal(value)
+			return string(content)
+		}
+
+		return value
+	}
+
+	return fmt.Sprint(value)
+}
+
+func yamlReserved(value string) bool {
+
+	switch strings.ToLower(value) {
+	case "true", "false", "yes", "no", "on", "off", "null", "~", "y", "n":
+		return true
+	}
+
+	_, err := strconv.ParseFloat(value, 64)
+
+	return err == nil
+}
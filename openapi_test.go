@@ -0,0 +1,97 @@
+package gsweb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+type openAPITestRequest struct {
+	ID      string `path:"id"`
+	QueryID int    `query:"id" validate:"required"`
+	Limit   int    `query:"limit"`
+}
+
+type openAPITestHandler struct{}
+
+func (openAPITestHandler) HandleGet(context *Context) error {
+	return context.Text(200, "ok")
+}
+
+func TestOpenAPIParameters(t *testing.T) {
+
+	router := newRouter()
+
+	uri := NewURIHandler()
+	uri.Handle(`/users/:id(\d+)`, openAPITestHandler{}).Describe("GET", Operation{Request: openAPITestRequest{}})
+	router.ChainHandle("uri", uri)
+
+	content, err := json.Marshal(router.OpenAPI(OpenAPIInfo{Title: "test", Version: "1"}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var document struct {
+		Paths map[string]map[string]struct {
+			Parameters []struct {
+				Name     string                 `json:"name"`
+				In       string                 `json:"in"`
+				Required bool                   `json:"required"`
+				Schema   map[string]interface{} `json:"schema"`
+			} `json:"parameters"`
+		} `json:"paths"`
+	}
+
+	if err := json.Unmarshal(content, &document); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+
+	for _, param := range document.Paths["/users/{id}"]["get"].Parameters {
+		got = append(got, fmt.Sprintf("%s %s %s %v", param.In, param.Name, param.Schema["type"], param.Required))
+
+		if pattern := param.Schema["pattern"]; pattern != nil && param.In != "path" {
+			t.Errorf("%s %s inherits path pattern %v", param.In, param.Name, pattern)
+		}
+	}
+
+	expect := []string{
+		"path id string true",
+		"query id integer true",
+		"query limit integer false",
+	}
+
+	if strings.Join(got, ",") != strings.Join(expect, ",") {
+		t.Errorf("parameters got %q, expect %q", got, expect)
+	}
+}
+
+func TestOpenAPIHandlerPage(t *testing.T) {
+
+	router := newRouter()
+	router.ChainHandle("security", NewSecurityHeaders(SecurityHeadersConfig{}))
+	router.ChainHandle("openapi", NewOpenAPIHandler("/_gsweb/api/", OpenAPIInfo{Title: "test", Version: "1"}))
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/_gsweb/api", nil))
+
+	body := recorder.Body.String()
+
+	policy := recorder.Header().Get("Content-Security-Policy")
+
+	// the inline style and script are allowed by the default nonce based policy
+	for _, tag := range []string{"style", "script"} {
+
+		nonce := regexp.MustCompile(`<` + tag + ` nonce="([^"]+)">`).FindStringSubmatch(body)
+
+		if nonce == nil || !strings.Contains(policy, tag+"-src 'self' 'nonce-"+nonce[1]+"'") {
+			t.Errorf("%s nonce %v not allowed by %q", tag, nonce, policy)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package gsweb
+
+import (
+	"html/template"
+	"strings"
+
+	"github.com/gsdocker/gslogger"
+)
+
+var openAPIPage = template.Must(template.New("openapi").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style nonce="{{.Nonce}}">
+body { font-family: sans-serif; margin: 2em; color: #222; }
+.op { border: 1px solid #ccc; border-radius: 4px; margin-bottom: 1em; }
+.op > summary { padding: 6px 10px; cursor: pointer; background: #f7f7f7; }
+.op .body { padding: 6px 16px; }
+.method { display: inline-block; width: 5em; font-weight: bold; text-transform: uppercase; }
+.get { color: #1b6ac9; } .post { color: #2f8132; } .put { color: #b8860b; }
+.patch { color: #8a4baf; } .delete { color: #c62828; }
+table { border-collapse: collapse; margin: 6px 0; }
+th, td { border: 1px solid #ddd; padding: 3px 8px; text-align: left; }
+pre { background: #f4f4f4; padding: 6px; overflow: auto; }
+code { font-family: monospace; }
+</style>
+</head>
+<body>
+<h1 id="title">{{.Title}}</h1>
+<p><a href="{{.JSON}}">openapi.json</a> | <a href="{{.YAML}}">openapi.yaml</a></p>
+<div id="paths">loading...</div>
+<script nonce="{{.Nonce}}">
+(function () {
+	function el(tag, attrs, children) {
+		var node = document.createElement(tag);
+		for (var key in attrs || {}) { node.setAttribute(key, attrs[key]); }
+		(children || []).forEach(function (child) {
+			node.appendChild(typeof child === "string" ? document.createTextNode(child) : child);
+		});
+		return node;
+	}
+
+	function schema(value) {
+		return el("pre", {}, [JSON.stringify(value, null, 2)]);
+	}
+
+	function operation(path, method, op) {
+		var body = el("div", {"class": "body"});
+		if (op.description) { body.appendChild(el("p", {}, [op.description])); }
+		if (op.parameters) {
+			var table = el("table", {}, [el("tr", {}, [el("th", {}, ["name"]), el("th", {}, ["in"]), el("th", {}, ["required"]), el("th", {}, ["schema"])])]);
+			op.parameters.forEach(function (p) {
+				table.appendChild(el("tr", {}, [el("td", {}, [el("code", {}, [p.name])]), el("td", {}, [p.in]), el("td", {}, [p.required ? "yes" : ""]), el("td", {}, [JSON.stringify(p.schema)])]));
+			});
+			body.appendChild(el("h4", {}, ["parameters"]));
+			body.appendChild(table);
+		}
+		if (op.requestBody) {
+			body.appendChild(el("h4", {}, ["request body"]));
+			body.appendChild(schema(op.requestBody.content["application/json"].schema));
+		}
+		body.appendChild(el("h4", {}, ["responses"]));
+		Object.keys(op.responses).sort().forEach(function (code) {
+			var response = op.responses[code];
+			body.appendChild(el("p", {}, [el("code", {}, [code]), " " + response.description]));
+			for (var type in response.content || {}) { body.appendChild(schema(response.content[type].schema)); }
+		});
+		return el("details", {"class": "op"}, [
+			el("summary", {}, [el("span", {"class": "method " + method}, [method]), el("code", {}, [path]), " " + (op.summary || "")]),
+			body
+		]);
+	}
+
+	fetch({{.JSON}}).then(function (response) { return response.json(); }).then(function (doc) {
+		var root = document.getElementById("paths");
+		root.textContent = "";
+		document.getElementById("title").textContent = doc.info.title + " " + doc.info.version;
+		if (doc.info.description) { root.appendChild(el("p", {}, [doc.info.description])); }
+		Object.keys(doc.paths).sort().forEach(function (path) {
+			["get", "put", "post", "patch", "delete", "head", "options"].forEach(function (method) {
+				if (doc.paths[path][method]) { root.appendChild(operation(path, method, doc.paths[path][method])); }
+			});
+		});
+	}).catch(function (err) {
+		document.getElementById("paths").textContent = "load openapi document error : " + err;
+	});
+})();
+</script>
+</body>
+</html>
+`))
+
+// OpenAPIHandler the chain node serving the OpenAPI document generated from
+// router's routes at prefix/openapi.json and prefix/openapi.yaml, and an
+// offline document viewer at prefix
+type OpenAPIHandler struct {
+	gslogger.Log             // Mixin log APIs
+	prefix       string      // the mounted uri prefix
+	info         OpenAPIInfo // the document info object
+}
+
+// NewOpenAPIHandler create OpenAPI handler mounted on prefix, e.g. /_gsweb/api
+func NewOpenAPIHandler(prefix string, info OpenAPIInfo) *OpenAPIHandler {
+	return &OpenAPIHandler{
+		Log:    gslogger.Get("openapi"),
+		prefix: strings.TrimSuffix(prefix, "/"),
+		info:   info,
+	}
+}
+
+// HandleGet implement Get interface
+func (handler *OpenAPIHandler) HandleGet(context *Context) error {
+
+	switch context.RequestURI() {
+	case handler.prefix + "/openapi.json":
+		context.Response().Header().Set("Cache-Control", "no-store")
+
+		return context.JSON(200, context.Router.OpenAPI(handler.info))
+
+	case handler.prefix + "/openapi.yaml":
+		content, err := EncodeYAML(context.Router.OpenAPI(handler.info))
+
+		if err != nil {
+			return context.Failed(err, "encode openapi yaml error")
+		}
+
+		context.Response().Header().Set("Cache-Control", "no-store")
+
+		return context.write(200, "application/yaml; charset=utf-8", content)
+
+	case handler.prefix, handler.prefix + "/":
+		return context.HTML(200, openAPIPage, map[string]string{
+			"Title": handler.info.Title,
+			"JSON":  handler.prefix + "/openapi.json",
+			"YAML":  handler.prefix + "/openapi.yaml",
+			"Nonce": context.CSPNonce(),
+		})
+	}
+
+	return context.Forward()
+}
@@ -0,0 +1,67 @@
+package gsweb
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gsdocker/gserrors"
+)
+
+// trustedProxies the trusted proxy networks whose forwarded headers are honored
+type trustedProxies []*net.IPNet
+
+// parseTrustedProxies parse trusted proxy ips or CIDRs
+func parseTrustedProxies(proxies []string) trustedProxies {
+
+	var networks trustedProxies
+
+	for _, proxy := range proxies {
+
+		if !strings.Contains(proxy, "/") {
+			if strings.Contains(proxy, ":") {
+				proxy += "/128"
+			} else {
+				proxy += "/32"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(proxy)
+		gserrors.Assert(err == nil, "invalid trusted proxy %s : %s", proxy, err)
+
+		networks = append(networks, network)
+	}
+
+	return networks
+}
+
+// contains check if ip is trusted proxy
+func (proxies trustedProxies) contains(ip net.IP) bool {
+	for _, network := range proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// remoteHost get the request's peer host
+func remoteHost(request *http.Request) string {
+
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+
+	if err != nil {
+		return request.RemoteAddr
+	}
+
+	return host
+}
+
+// forwarded check if request is forwarded by trusted proxy
+func (proxies trustedProxies) forwarded(request *http.Request) bool {
+
+	ip := net.ParseIP(remoteHost(request))
+
+	return ip != nil && proxies.contains(ip)
+}
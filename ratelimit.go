@@ -0,0 +1,344 @@
+package gsweb
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gsdocker/gslogger"
+)
+
+// RateLimitAlgorithm the rate limit algorithm
+type RateLimitAlgorithm int
+
+// Rate limit algorithms
+const (
+	TokenBucket   RateLimitAlgorithm = iota // bucket of Limit tokens refilled evenly over Window, allows bursts up to Limit
+	SlidingWindow                           // at most Limit requests in any Window, estimated by weighted previous window
+)
+
+// RateLimitRule the rate limit rule
+type RateLimitRule struct {
+	Limit     int                // requests allowed per window, zero or negative disables limiting
+	Window    time.Duration      // the window duration
+	Algorithm RateLimitAlgorithm // the rate limit algorithm, default TokenBucket
+}
+
+// RateLimitResult the rate limit decision of request
+type RateLimitResult struct {
+	Allowed    bool          // indicate if request allowed
+	Limit      int           // the rule limit
+	Remaining  int           // remaining requests in current window
+	Reset      time.Duration // duration until quota fully restored
+	RetryAfter time.Duration // duration until next request allowed, zero if allowed
+}
+
+// RateLimitStore the rate limit state store, implement it for shared stores,
+// e.g. redis, when running multiple instances
+type RateLimitStore interface {
+	// Take consume one request of key by rule at now
+	Take(key string, rule RateLimitRule, now time.Time) (RateLimitResult, error)
+}
+
+// rateLimitState the rate limit state of key
+type rateLimitState struct {
+	tokens   float64   // token bucket tokens
+	start    time.Time // sliding window current window start time
+	previous int       // sliding window previous window count
+	current  int       // sliding window current window count
+	accessed time.Time // last access time
+	idle     time.Time // the time after which the state equals a fresh one, used to purge idle states
+}
+
+// MemoryRateLimitStore the in-memory rate limit store, idle states are purged
+// periodically
+type MemoryRateLimitStore struct {
+	mutex     sync.Mutex                 // states guard
+	states    map[string]*rateLimitState // states indexed by key
+	lastPurge time.Time                  // last purge time
+}
+
+// NewMemoryRateLimitStore create in-memory rate limit store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		states:    make(map[string]*rateLimitState),
+		lastPurge: time.Now(),
+	}
+}
+
+// Take implement RateLimitStore
+func (store *MemoryRateLimitStore) Take(key string, rule RateLimitRule, now time.Time) (RateLimitResult, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if now.Sub(store.lastPurge) > time.Minute {
+		store.purge(now)
+	}
+
+	state, ok := store.states[key]
+
+	if !ok {
+		state = &rateLimitState{tokens: float64(rule.Limit), accessed: now}
+		store.states[key] = state
+	}
+
+	// the token bucket refills in one window, the sliding window forgets the
+	// previous window count after two windows
+	state.idle = now.Add(2 * rule.Window)
+
+	if rule.Algorithm == SlidingWindow {
+		return takeSlidingWindow(state, rule, now), nil
+	}
+
+	return takeTokenBucket(state, rule, now), nil
+}
+
+// purge remove states idle long enough to equal fresh ones, must be called
+// with mutex held
+func (store *MemoryRateLimitStore) purge(now time.Time) {
+
+	store.lastPurge = now
+
+	for key, state := range store.states {
+		if now.After(state.idle) {
+			delete(store.states, key)
+		}
+	}
+}
+
+func takeTokenBucket(state *rateLimitState, rule RateLimitRule, now time.Time) RateLimitResult {
+
+	limit := float64(rule.Limit)
+
+	// tokens refilled per second
+	rate := limit / rule.Window.Seconds()
+
+	state.tokens = math.Min(limit, state.tokens+now.Sub(state.accessed).Seconds()*rate)
+	state.accessed = now
+
+	result := RateLimitResult{Limit: rule.Limit}
+
+	if state.tokens >= 1 {
+		state.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = time.Duration((1 - state.tokens) / rate * float64(time.Second))
+	}
+
+	result.Remaining = int(state.tokens)
+	result.Reset = time.Duration((limit - state.tokens) / rate * float64(time.Second))
+
+	return result
+}
+
+func takeSlidingWindow(state *rateLimitState, rule RateLimitRule, now time.Time) RateLimitResult {
+
+	start := now.Truncate(rule.Window)
+
+	if !state.start.Equal(start) {
+		if start.Sub(state.start) == rule.Window {
+			state.previous = state.current
+		} else {
+			state.previous = 0
+		}
+
+		state.current = 0
+		state.start = start
+	}
+
+	state.accessed = now
+
+	elapsed := now.Sub(start)
+
+	weight := 1 - float64(elapsed)/float64(rule.Window)
+
+	estimated := float64(state.previous)*weight + float64(state.current)
+
+	result := RateLimitResult{Limit: rule.Limit, Reset: rule.Window - elapsed}
+
+	if estimated+1 <= float64(rule.Limit) {
+		state.current++
+		estimated++
+		result.Allowed = true
+	} else if free := float64(rule.Limit - 1 - state.current); free >= 0 && state.previous > 0 {
+		// wait until the previous window weight drops enough
+		result.RetryAfter = time.Duration((1-free/float64(state.previous))*float64(rule.Window)) - elapsed
+	} else {
+		result.RetryAfter = rule.Window - elapsed
+	}
+
+	if state.previous > 0 {
+		result.Reset += rule.Window
+	}
+
+	result.Remaining = int(math.Max(0, math.Floor(float64(rule.Limit)-estimated)))
+
+	return result
+}
+
+// RateLimitConfig the rate limit chain node config
+type RateLimitConfig struct {
+	Rule           RateLimitRule  // the default rule
+	KeyHeader      string         // limit by request header, e.g. X-Client-ID set by API gateway, honored only for requests from trusted proxies
+	ByPrincipal    bool           // limit authenticated requests by Context.Principal set by Auth chain node registered before, takes precedence over KeyHeader
+	TrustedProxies []string       // trusted proxy ips or CIDRs, X-Forwarded-For is honored for requests from them
+	Store          RateLimitStore // rate limit store, default MemoryRateLimitStore
+}
+
+// rateLimitRoute the rule overridden under uri prefix
+type rateLimitRoute struct {
+	prefix string        // uri prefix
+	rule   RateLimitRule // rule
+}
+
+// RateLimit the chain node rejecting requests exceeding rate limit by 429 Too
+// Many Requests
+type RateLimit struct {
+	gslogger.Log                  // Mixin log APIs
+	config       RateLimitConfig  // rate limit config
+	proxies      trustedProxies   // trusted proxies
+	routes       []rateLimitRoute // routes ordered by prefix length descending
+}
+
+// NewRateLimit create rate limit chain node
+func NewRateLimit(config RateLimitConfig) *RateLimit {
+
+	if config.Store == nil {
+		config.Store = NewMemoryRateLimitStore()
+	}
+
+	return &RateLimit{
+		Log:     gslogger.Get("ratelimit"),
+		config:  config,
+		proxies: parseTrustedProxies(config.TrustedProxies),
+	}
+}
+
+// Route override rule for requests under uri prefix, the rule of the longest
+// matched prefix wins, requests under different prefixes are limited separately
+func (rateLimit *RateLimit) Route(prefix string, rule RateLimitRule) *RateLimit {
+
+	rateLimit.routes = append(rateLimit.routes, rateLimitRoute{prefix: prefix, rule: rule})
+
+	sort.SliceStable(rateLimit.routes, func(i, j int) bool {
+		return len(rateLimit.routes[i].prefix) > len(rateLimit.routes[j].prefix)
+	})
+
+	return rateLimit
+}
+
+// clientIP get client ip, the X-Forwarded-For addresses appended by trusted
+// proxies are skipped from right to left
+func (rateLimit *RateLimit) clientIP(request *http.Request) string {
+
+	host := remoteHost(request)
+
+	if !rateLimit.proxies.forwarded(request) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(request.Header.Values("X-Forwarded-For"), ","), ",")
+
+	for i := len(forwarded) - 1; i >= 0; i-- {
+
+		address := strings.TrimSpace(forwarded[i])
+
+		forwardedIP := net.ParseIP(address)
+
+		if forwardedIP == nil {
+			break
+		}
+
+		host = forwardedIP.String()
+
+		if !rateLimit.proxies.contains(forwardedIP) {
+			break
+		}
+	}
+
+	return host
+}
+
+// key get the request's rate limit key, only verified identities are trusted,
+// client supplied values would let clients bypass the limit by changing them
+func (rateLimit *RateLimit) key(context *Context) string {
+
+	request := context.Request()
+
+	if principal := context.Principal(); rateLimit.config.ByPrincipal && principal != nil {
+		return "principal:" + principal.Provider + ":" + principal.Subject
+	}
+
+	if rateLimit.config.KeyHeader != "" && rateLimit.proxies.forwarded(request) {
+		if value := request.Header.Get(rateLimit.config.KeyHeader); value != "" {
+			return "header:" + value
+		}
+	}
+
+	return "ip:" + rateLimit.clientIP(request)
+}
+
+// HandleUnknown implement Unknown interface
+func (rateLimit *RateLimit) HandleUnknown(context *Context) error {
+
+	rule, scope := rateLimit.config.Rule, ""
+
+	for _, route := range rateLimit.routes {
+		if matchPrefix(context.RequestURI(), route.prefix) {
+			rule, scope = route.rule, route.prefix
+			break
+		}
+	}
+
+	if rule.Limit <= 0 || rule.Window <= 0 {
+		return context.Forward()
+	}
+
+	key := rateLimit.key(context)
+
+	result, err := rateLimit.config.Store.Take(scope+"|"+key, rule, time.Now())
+
+	// fail open so store outage doesn't take the site down
+	if err != nil {
+		rateLimit.W("take rate limit of %s error : %s", key, err)
+		return context.Forward()
+	}
+
+	header := context.Response().Header()
+
+	header.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+
+	if !result.Allowed {
+
+		retryAfter := ceilSeconds(result.RetryAfter)
+
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+
+		header.Set("Retry-After", strconv.Itoa(retryAfter))
+
+		rateLimit.D("%s %s rate limited : %s", context.RequestMethod(), context.RequestURI(), key)
+
+		err := NewHTTPError(http.StatusTooManyRequests, nil, "rate limit exceeded, retry after %d seconds", retryAfter)
+
+		return context.Failed(err, "%s %s rate limited", context.RequestMethod(), context.RequestURI())
+	}
+
+	return context.Forward()
+}
+
+func ceilSeconds(duration time.Duration) int {
+	if duration <= 0 {
+		return 0
+	}
+
+	return int(math.Ceil(duration.Seconds()))
+}
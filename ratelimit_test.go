@@ -0,0 +1,209 @@
+package gsweb
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimitAlgorithms(t *testing.T) {
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		rule    RateLimitRule
+		offsets []time.Duration // request times relative to start
+		allowed string          // expected decisions, 1 allowed, 0 rejected
+	}{
+		{
+			"token bucket burst",
+			RateLimitRule{Limit: 3, Window: time.Minute},
+			[]time.Duration{0, 0, 0, 0},
+			"1110",
+		},
+		{
+			"token bucket refill",
+			RateLimitRule{Limit: 3, Window: time.Minute},
+			[]time.Duration{0, 0, 0, 10 * time.Second, 20 * time.Second, 21 * time.Second},
+			"111010",
+		},
+		{
+			"sliding window",
+			RateLimitRule{Limit: 2, Window: time.Minute, Algorithm: SlidingWindow},
+			[]time.Duration{0, time.Second, 2 * time.Second, 61 * time.Second, 90 * time.Second, 121 * time.Second},
+			"110011",
+		},
+	}
+
+	for _, test := range tests {
+
+		store := NewMemoryRateLimitStore()
+
+		decisions := ""
+
+		for _, offset := range test.offsets {
+
+			result, err := store.Take("key", test.rule, start.Add(offset))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if result.Allowed {
+				decisions += "1"
+			} else {
+				decisions += "0"
+
+				if result.RetryAfter <= 0 {
+					t.Errorf("%s rejected without retry after", test.name)
+				}
+			}
+		}
+
+		if decisions != test.allowed {
+			t.Errorf("%s got %s, expect %s", test.name, decisions, test.allowed)
+		}
+	}
+}
+
+func TestMemoryRateLimitStorePurge(t *testing.T) {
+
+	store := NewMemoryRateLimitStore()
+
+	now := time.Now()
+
+	daily := RateLimitRule{Limit: 1, Window: 24 * time.Hour}
+
+	store.Take("daily", daily, now)
+	store.Take("short", RateLimitRule{Limit: 1, Window: time.Second}, now)
+
+	// the purge runs after a minute, the daily state must survive it
+	later := now.Add(2 * time.Hour)
+
+	if result, _ := store.Take("daily", daily, later); result.Allowed {
+		t.Error("daily limit reset by purge")
+	}
+
+	if _, ok := store.states["short"]; ok {
+		t.Error("idle short window state not purged")
+	}
+}
+
+type rateLimitTestHandler struct{}
+
+func (rateLimitTestHandler) HandleGet(context *Context) error {
+	return context.Text(200, "ok")
+}
+
+func TestRateLimitKey(t *testing.T) {
+
+	router := newRouter()
+	router.ChainHandle("auth", NewAuth(AuthConfig{}, NewAPIKeyAuth("", map[string]string{"key-1": "svc"})))
+	router.ChainHandle("ratelimit", NewRateLimit(RateLimitConfig{
+		Rule:           RateLimitRule{Limit: 2, Window: time.Minute},
+		KeyHeader:      "X-Client-ID",
+		ByPrincipal:    true,
+		TrustedProxies: []string{"10.0.0.0/8"},
+	}).Route("/free", RateLimitRule{}))
+
+	uri := NewURIHandler()
+	uri.Handle("/a", rateLimitTestHandler{})
+	uri.Handle("/free", rateLimitTestHandler{})
+	router.ChainHandle("uri", uri)
+
+	// headers are name value pairs
+	do := func(path string, remote string, forwarded string, headers ...string) int {
+
+		request := httptest.NewRequest("GET", path, nil)
+		request.RemoteAddr = remote
+
+		if forwarded != "" {
+			request.Header.Set("X-Forwarded-For", forwarded)
+		}
+
+		for i := 0; i+1 < len(headers); i += 2 {
+			request.Header.Set(headers[i], headers[i+1])
+		}
+
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, request)
+
+		return recorder.Code
+	}
+
+	codes := ""
+
+	// unverified header values must not open new buckets
+	for i := 0; i < 4; i++ {
+		codes += strconv.Itoa(do("/a", "192.0.2.1:1", "", "X-API-Key", "random-"+strconv.Itoa(i))) + " "
+	}
+
+	if codes != "401 401 401 401 " {
+		t.Errorf("invalid api keys got %s", codes)
+	}
+
+	codes = ""
+
+	for i := 0; i < 3; i++ {
+		codes += strconv.Itoa(do("/a", "192.0.2.2:1", "")) + " "
+	}
+
+	codes += strconv.Itoa(do("/a", "192.0.2.3:1", "")) + " "
+
+	if codes != "200 200 429 200 " {
+		t.Errorf("ip limit got %s", codes)
+	}
+
+	codes = ""
+
+	// the verified principal is limited separately from its ip
+	for i := 0; i < 3; i++ {
+		codes += strconv.Itoa(do("/a", "192.0.2.2:1", "", "X-API-Key", "key-1")) + " "
+	}
+
+	if codes != "200 200 429 " {
+		t.Errorf("principal limit got %s", codes)
+	}
+
+	codes = ""
+
+	// X-Forwarded-For is honored only from trusted proxies
+	for i := 0; i < 3; i++ {
+		codes += strconv.Itoa(do("/a", "10.0.0.1:1", "198.51.100.1, 10.0.0.2")) + " "
+	}
+
+	codes += strconv.Itoa(do("/a", "10.0.0.9:1", "198.51.100.2")) + " "
+	codes += strconv.Itoa(do("/a", "192.0.2.4:1", "198.51.100.2")) + " "
+
+	if codes != "200 200 429 200 200 " {
+		t.Errorf("forwarded limit got %s", codes)
+	}
+
+	codes = ""
+
+	// the key header is honored from trusted proxies only
+	for _, client := range []string{"a", "a", "a", "b"} {
+		codes += strconv.Itoa(do("/a", "10.0.0.3:1", "", "X-Client-ID", client)) + " "
+	}
+
+	if codes != "200 200 429 200 " {
+		t.Errorf("trusted key header limit got %s", codes)
+	}
+
+	codes = ""
+
+	for i := 0; i < 3; i++ {
+		codes += strconv.Itoa(do("/a", "192.0.2.5:1", "", "X-Client-ID", "client-"+strconv.Itoa(i))) + " "
+	}
+
+	if codes != "200 200 429 " {
+		t.Errorf("untrusted key header limit got %s", codes)
+	}
+
+	if code := do("/free", "192.0.2.2:1", ""); code != 200 {
+		t.Errorf("unlimited route got %d", code)
+	}
+}
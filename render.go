@@ -0,0 +1,187 @@
+package gsweb
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// qualityValue the item of quality value list header, e.g. Accept, Accept-Encoding
+type qualityValue struct {
+	value string  // the item value with parameters except q stripped
+	q     float64 // the item quality
+}
+
+// parseQualityList parse quality value list header, the items are sorted by
+// quality descending, items with same quality keep header order
+func parseQualityList(header string) []qualityValue {
+
+	var values []qualityValue
+
+	for _, item := range strings.Split(header, ",") {
+
+		parts := strings.Split(item, ";")
+
+		value := strings.ToLower(strings.TrimSpace(parts[0]))
+
+		if value == "" {
+			continue
+		}
+
+		q := 1.0
+
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		values = append(values, qualityValue{value: value, q: q})
+	}
+
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].q > values[j].q
+	})
+
+	return values
+}
+
+// negotiateContentType select the best media type in offers by Accept header,
+// returns the first offer if the header is empty, or empty string if none acceptable
+func negotiateContentType(accept string, offers []string) string {
+
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	best, bestQ, bestSpecificity := "", 0.0, -1
+
+	for _, spec := range parseQualityList(accept) {
+
+		if spec.q <= 0 {
+			continue
+		}
+
+		for _, offer := range offers {
+
+			specificity := -1
+
+			switch {
+			case spec.value == offer:
+				specificity = 2
+			case strings.HasSuffix(spec.value, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(spec.value, "*")):
+				specificity = 1
+			case spec.value == "*/*":
+				specificity = 0
+			}
+
+			if specificity == -1 {
+				continue
+			}
+
+			if spec.q > bestQ || (spec.q == bestQ && specificity > bestSpecificity) {
+				best, bestQ, bestSpecificity = offer, spec.q, specificity
+			}
+		}
+	}
+
+	return best
+}
+
+// write write response with content type and status code then mark the
+// request as handled
+func (context *Context) write(code int, contentType string, content []byte) error {
+
+	header := context.Response().Header()
+
+	header.Set("Content-Type", contentType)
+	header.Set("Content-Length", strconv.Itoa(len(content)))
+
+	context.Response().WriteHeader(code)
+
+	if _, err := context.Response().Write(content); err != nil {
+		context.W("%s %s write response error : %s", context.RequestMethod(), context.RequestURI(), err)
+	}
+
+	return context.Success()
+}
+
+// JSON write v as application/json response and mark the request as handled
+func (context *Context) JSON(code int, v interface{}) error {
+
+	content, err := json.Marshal(v)
+
+	if err != nil {
+		return context.Failed(err, "encode json response error")
+	}
+
+	return context.write(code, "application/json; charset=utf-8", content)
+}
+
+// XML write v as application/xml response and mark the request as handled
+func (context *Context) XML(code int, v interface{}) error {
+	return context.xml(code, "application/xml", v)
+}
+
+// xml write v as xml response of media type, e.g. application/xml or text/xml
+func (context *Context) xml(code int, mediaType string, v interface{}) error {
+
+	content, err := xml.Marshal(v)
+
+	if err != nil {
+		return context.Failed(err, "encode xml response error")
+	}
+
+	return context.write(code, mediaType+"; charset=utf-8", append([]byte(xml.Header), content...))
+}
+
+// Text write formatted text/plain response and mark the request as handled
+func (context *Context) Text(code int, format string, args ...interface{}) error {
+	return context.write(code, "text/plain; charset=utf-8", []byte(fmt.Sprintf(format, args...)))
+}
+
+// HTML execute html template with data as text/html response and mark the
+// request as handled
+func (context *Context) HTML(code int, tpl *template.Template, data interface{}) error {
+
+	var buff bytes.Buffer
+
+	if err := tpl.Execute(&buff, data); err != nil {
+		return context.Failed(err, "execute template %s error", tpl.Name())
+	}
+
+	return context.write(code, "text/html; charset=utf-8", buff.Bytes())
+}
+
+var negotiateOffers = []string{"application/json", "application/xml", "text/xml", "text/plain"}
+
+// Negotiate write data as json, xml or plain text response by request's Accept
+// header and mark the request as handled, marks the request failed with 406
+// Not Acceptable if none of them is acceptable
+func (context *Context) Negotiate(code int, data interface{}) error {
+
+	context.Response().Header().Add("Vary", "Accept")
+
+	switch offer := negotiateContentType(context.Request().Header.Get("Accept"), negotiateOffers); offer {
+	case "application/json":
+		return context.JSON(code, data)
+	case "application/xml", "text/xml":
+		return context.xml(code, offer, data)
+	case "text/plain":
+		return context.Text(code, "%v", data)
+	}
+
+	err := NewHTTPError(http.StatusNotAcceptable, nil, "acceptable types : %s", strings.Join(negotiateOffers, ", "))
+
+	return context.Failed(err, "%s %s not acceptable", context.RequestMethod(), context.RequestURI())
+}
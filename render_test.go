@@ -0,0 +1,80 @@
+package gsweb
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+type renderTestItem struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func (item renderTestItem) String() string {
+	return "item " + item.Name
+}
+
+type renderTestHandler struct{}
+
+func (renderTestHandler) HandleGet(context *Context) error {
+	return context.Negotiate(200, renderTestItem{Name: "pen"})
+}
+
+func TestNegotiate(t *testing.T) {
+
+	router := newRouter()
+
+	uri := NewURIHandler()
+	uri.Handle("/item", renderTestHandler{})
+	router.ChainHandle("uri", uri)
+
+	const xmlBody = `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<renderTestItem><name>pen</name></renderTestItem>`
+
+	tests := []struct {
+		accept      string
+		code        int
+		contentType string
+		body        string
+	}{
+		{"", 200, "application/json; charset=utf-8", `{"name":"pen"}`},
+		{"application/json", 200, "application/json; charset=utf-8", `{"name":"pen"}`},
+		{"application/xml", 200, "application/xml; charset=utf-8", xmlBody},
+		{"text/xml", 200, "text/xml; charset=utf-8", xmlBody},
+		{"text/*;q=0.5, text/plain", 200, "text/plain; charset=utf-8", "item pen"},
+		{"text/html, application/xml;q=0.9", 200, "application/xml; charset=utf-8", xmlBody},
+		{"image/png", 406, "", ""},
+	}
+
+	for _, test := range tests {
+
+		request := httptest.NewRequest("GET", "/item", nil)
+
+		if test.accept != "" {
+			request.Header.Set("Accept", test.accept)
+		}
+
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != test.code {
+			t.Errorf("Accept %q code got %d, expect %d", test.accept, recorder.Code, test.code)
+			continue
+		}
+
+		if got := recorder.Header().Get("Vary"); got != "Accept" {
+			t.Errorf("Accept %q vary got %q", test.accept, got)
+		}
+
+		if test.code != 200 {
+			continue
+		}
+
+		if got := recorder.Header().Get("Content-Type"); got != test.contentType {
+			t.Errorf("Accept %q content type got %q, expect %q", test.accept, got, test.contentType)
+		}
+
+		if recorder.Body.String() != test.body {
+			t.Errorf("Accept %q body got %q, expect %q", test.accept, recorder.Body.String(), test.body)
+		}
+	}
+}
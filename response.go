@@ -0,0 +1,83 @@
+package gsweb
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	"github.com/gsdocker/gserrors"
+)
+
+// responseWriter the http.ResponseWriter wrapper tracking response state
+type responseWriter struct {
+	http.ResponseWriter          // Mixin underlying response writer
+	status              int      // written status code
+	size                int64    // written body bytes
+	written             bool     // indicate if the response header was written
+	beforeWrite         []func() // hooks called before writing response header
+	discardBody         bool     // discard response body, used by HEAD request
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w}
+}
+
+// WriteHeader implement http.ResponseWriter
+func (w *responseWriter) WriteHeader(code int) {
+	if w.written {
+		return
+	}
+
+	w.written = true
+
+	for _, hook := range w.beforeWrite {
+		hook()
+	}
+
+	w.status = code
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implement http.ResponseWriter
+func (w *responseWriter) Write(buff []byte) (int, error) {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.discardBody {
+		return len(buff), nil
+	}
+
+	n, err := w.ResponseWriter.Write(buff)
+
+	w.size += int64(n)
+
+	return n, err
+}
+
+// Flush implement http.Flusher
+func (w *responseWriter) Flush() {
+	if !w.written {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack implement http.Hijacker
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		w.written = true
+		return hijacker.Hijack()
+	}
+
+	return nil, nil, gserrors.Newf(nil, "underlying response writer not implement http.Hijacker")
+}
+
+// Unwrap support http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -1,54 +1,322 @@
 package gsweb
 
 import (
+	"fmt"
 	"net/http"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
 
+	"github.com/gsdocker/gserrors"
 	"github.com/gsdocker/gslogger"
 )
 
 // Handler the http process handler
 type Handler struct {
 	name    string                   // The handler name
+	target  interface{}              // The registered handler object
 	methods map[string]MethodHandler // methods
 }
 
 // Router resource router
 type Router struct {
-	gslogger.Log            // Mixin log APIs
-	handleChain  []*Handler // request handle chain
-	started      bool       // the gsweb state flag
+	gslogger.Log                                // Mixin log APIs
+	handleChain      atomic.Pointer[[]*Handler] // request handle chain snapshot
+	chainMutex       sync.Mutex                 // handle chain writers guard
+	errorRenderer    ErrorRenderer              // the error response renderer
+	notFound         MethodHandler              // handler called when no chain node handled the request
+	methodNotAllowed MethodHandler              // handler called when the uri exists but not for the method
+	templates        *TemplateEngine            // the template engine used by Context.Render
+	metrics          *Metrics                   // the request metrics, nil if disabled
+	tracer           *Tracer                    // the request tracer, nil if disabled
 }
 
 func newRouter() *Router {
-	return &Router{
-		Log: gslogger.Get("router"),
+	router := &Router{
+		Log:              gslogger.Get("router"),
+		errorRenderer:    &HTMLErrorRenderer{},
+		notFound:         defaultNotFound,
+		methodNotAllowed: defaultMethodNotAllowed,
 	}
+
+	router.handleChain.Store(&[]*Handler{})
+
+	return router
+}
+
+func defaultNotFound(context *Context) error {
+	return NewHTTPError(http.StatusNotFound, nil, "")
+}
+
+func defaultMethodNotAllowed(context *Context) error {
+	return NewHTTPError(http.StatusMethodNotAllowed, nil, "")
 }
 
 // ServeHTTP implement http handler
 func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	// empty handleChain optimize
-	if len(router.handleChain) == 0 {
+	context := newContext(router, r, w)
+
+	if len(context.handleChain) == 0 {
 		router.W("gsweb empty handle chain warning !!!!!! ")
-		return
 	}
 
-	context := newContext(router, r, w)
+	if router.tracer != nil {
+		router.tracer.begin(context)
+		context.OnFinish(func() { router.tracer.end(context) })
+	}
+
+	if router.metrics != nil {
+		router.metrics.begin(context)
+		context.OnFinish(func() { router.metrics.end(context) })
+	}
+
+	defer func() {
+		for i := len(context.finish) - 1; i >= 0; i-- {
+			context.finish[i]()
+		}
+	}()
+
+	defer func() {
+		if e := recover(); e != nil {
+
+			if e == http.ErrAbortHandler {
+				panic(e)
+			}
+
+			cause, ok := e.(error)
+
+			if !ok {
+				cause = fmt.Errorf("%v", e)
+			}
+
+			context.E(
+				"handle request panic :\n\tfrom:%s\n\trequest-uri:%s\n\tpanic:%s\n%s",
+				r.RemoteAddr,
+				r.RequestURI,
+				cause,
+				debug.Stack(),
+			)
+
+			router.renderError(context, NewHTTPError(http.StatusInternalServerError, cause, ""))
+		}
+	}()
+
 	err := context.Forward()
 
+	if err == nil && !context.Handled() {
+		err = router.unhandled(context)
+	}
+
 	if err != nil {
-		router.E(
-			"handle request err :\n\tfrom:%s\n\trequest-uri:%s\n\terr:%s",
-			r.RemoteAddr,
-			r.RequestURI,
-			err,
-		)
+
+		if context.failure != nil {
+			err = context.failure
+		}
+
+		httpError := AsHTTPError(err)
+
+		if httpError.Code >= http.StatusInternalServerError {
+			context.E(
+				"handle request err :\n\tfrom:%s\n\trequest-uri:%s\n\terr:%s",
+				r.RemoteAddr,
+				r.RequestURI,
+				err,
+			)
+		} else {
+			context.D("%s %s from %s : %s", r.Method, r.RequestURI, r.RemoteAddr, err)
+		}
+
+		router.renderError(context, httpError)
+	}
+
+	// commit response header for handlers only calling Success
+	if !context.Written() {
+		context.responseWriter.WriteHeader(http.StatusOK)
+	}
+}
+
+// unhandled call the NotFound or MethodNotAllowed handler
+func (router *Router) unhandled(context *Context) error {
+
+	if len(context.allowMethods) > 0 {
+		context.Response().Header().Set("Allow", strings.Join(context.allowMethods, ", "))
+		return router.methodNotAllowed(context)
+	}
+
+	return router.notFound(context)
+}
+
+// renderError write error response if the response header not written yet
+func (router *Router) renderError(context *Context, err *HTTPError) {
+
+	if context.Written() {
+		context.W("%s %s response already written, skip render error : %s", context.RequestMethod(), context.RequestURI(), err)
+		return
 	}
+
+	router.errorRenderer.RenderError(context, err)
+}
+
+// SetErrorRenderer set the renderer converting handler's errors and panics
+// into http response, default is HTMLErrorRenderer
+func (router *Router) SetErrorRenderer(renderer ErrorRenderer) {
+	router.errorRenderer = renderer
+}
+
+// SetNotFound set the handler called when no chain node handled the request,
+// the default handler responds 404 Not Found by the error renderer
+func (router *Router) SetNotFound(handler MethodHandler) {
+	router.notFound = handler
+}
+
+// SetMethodNotAllowed set the handler called when URIHandler found the request
+// uri but not for the request method, the Allow header is set before calling it.
+// the default handler responds 405 Method Not Allowed by the error renderer
+func (router *Router) SetMethodNotAllowed(handler MethodHandler) {
+	router.methodNotAllowed = handler
+}
+
+// SetMetrics set the metrics recording request counters, latency histograms
+// and in-flight gauges, expose it by MetricsHandler
+func (router *Router) SetMetrics(metrics *Metrics) {
+	router.metrics = metrics
 }
 
-// ChainHandle register request handle chain node named by parameter name
-// the method is not thread safe,so call it before calling WebSite#Run method
+// SetTracer set the tracer recording a span per chain node entered through
+// Context.Forward, the W3C traceparent header is propagated in and out
+func (router *Router) SetTracer(tracer *Tracer) {
+	router.tracer = tracer
+}
+
+// chain get current handle chain snapshot, the snapshot must not be modified
+func (router *Router) chain() []*Handler {
+	return *router.handleChain.Load()
+}
+
+// updateChain replace handle chain by the result of update called with a copy
+// of current chain, in-flight requests keep using the snapshot they started with
+func (router *Router) updateChain(update func(chain []*Handler) ([]*Handler, error)) error {
+	router.chainMutex.Lock()
+	defer router.chainMutex.Unlock()
+
+	current := router.chain()
+
+	chain, err := update(append([]*Handler(nil), current...))
+
+	if err != nil {
+		return err
+	}
+
+	router.handleChain.Store(&chain)
+
+	return nil
+}
+
+func newHandler(name string, handler interface{}) *Handler {
+	return &Handler{name: name, target: handler, methods: ExtractMethods(handler)}
+}
+
+func indexHandler(chain []*Handler, name string) int {
+	for i, handler := range chain {
+		if handler.name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ChainHandle register request handle chain node named by parameter name,
+// the method is thread safe and can be called at runtime
 func (router *Router) ChainHandle(name string, handler interface{}) {
-	router.handleChain = append(router.handleChain, &Handler{name: name, methods: ExtractMethods(handler)})
+	router.updateChain(func(chain []*Handler) ([]*Handler, error) {
+		return append(chain, newHandler(name, handler)), nil
+	})
+}
+
+// RemoveHandle remove the first chain node named by parameter name
+func (router *Router) RemoveHandle(name string) error {
+	return router.updateChain(func(chain []*Handler) ([]*Handler, error) {
+		i := indexHandler(chain, name)
+
+		if i == -1 {
+			return nil, gserrors.Newf(nil, "chain node %s not found", name)
+		}
+
+		return append(chain[:i], chain[i+1:]...), nil
+	})
+}
+
+// ReplaceHandle replace the handler of the first chain node named by parameter name
+func (router *Router) ReplaceHandle(name string, handler interface{}) error {
+	return router.updateChain(func(chain []*Handler) ([]*Handler, error) {
+		i := indexHandler(chain, name)
+
+		if i == -1 {
+			return nil, gserrors.Newf(nil, "chain node %s not found", name)
+		}
+
+		chain[i] = newHandler(name, handler)
+
+		return chain, nil
+	})
+}
+
+// InsertHandleBefore insert chain node named by parameter name before the
+// first chain node named by parameter target
+func (router *Router) InsertHandleBefore(target string, name string, handler interface{}) error {
+	return router.insertHandle(target, 0, name, handler)
+}
+
+// InsertHandleAfter insert chain node named by parameter name after the
+// first chain node named by parameter target
+func (router *Router) InsertHandleAfter(target string, name string, handler interface{}) error {
+	return router.insertHandle(target, 1, name, handler)
+}
+
+func (router *Router) insertHandle(target string, offset int, name string, handler interface{}) error {
+	return router.updateChain(func(chain []*Handler) ([]*Handler, error) {
+		i := indexHandler(chain, target)
+
+		if i == -1 {
+			return nil, gserrors.Newf(nil, "chain node %s not found", target)
+		}
+
+		i += offset
+
+		chain = append(chain, nil)
+		copy(chain[i+1:], chain[i:])
+		chain[i] = newHandler(name, handler)
+
+		return chain, nil
+	})
+}
+
+// ReorderHandles reorder handle chain, names must be the permutation of all
+// chain nodes' names
+func (router *Router) ReorderHandles(names ...string) error {
+	return router.updateChain(func(chain []*Handler) ([]*Handler, error) {
+
+		if len(names) != len(chain) {
+			return nil, gserrors.Newf(nil, "reorder expect %d chain node names, got %d", len(chain), len(names))
+		}
+
+		reordered := make([]*Handler, 0, len(chain))
+
+		for _, name := range names {
+			i := indexHandler(chain, name)
+
+			if i == -1 {
+				return nil, gserrors.Newf(nil, "chain node %s not found or duplicated", name)
+			}
+
+			reordered = append(reordered, chain[i])
+
+			chain = append(chain[:i], chain[i+1:]...)
+		}
+
+		return reordered, nil
+	})
 }
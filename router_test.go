@@ -0,0 +1,84 @@
+package gsweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type routerTestHandler struct{}
+
+func (routerTestHandler) HandleGet(context *Context) error {
+	return context.Text(200, "get")
+}
+
+func (routerTestHandler) HandlePost(context *Context) error {
+	return context.Text(200, "post")
+}
+
+func TestRouterUnhandled(t *testing.T) {
+
+	newTestRouter := func() *Router {
+
+		router := newRouter()
+
+		first := NewURIHandler()
+		first.Handle("/a", routerTestHandler{})
+		router.ChainHandle("first", first)
+
+		// the same uri in another node serving other methods
+		second := NewURIHandler()
+		second.HandleMethod("DELETE", "/a", func(context *Context) error {
+			return context.Text(200, "delete")
+		})
+		router.ChainHandle("second", second)
+
+		return router
+	}
+
+	custom := newTestRouter()
+
+	custom.SetNotFound(func(context *Context) error {
+		return context.Text(http.StatusNotFound, "custom not found %s", context.RequestURI())
+	})
+
+	custom.SetMethodNotAllowed(func(context *Context) error {
+		return context.Text(http.StatusMethodNotAllowed, "custom not allowed, allow %s", context.Response().Header().Get("Allow"))
+	})
+
+	tests := []struct {
+		name   string
+		router *Router
+		method string
+		path   string
+		code   int
+		allow  string
+		body   string // expected body, empty for not checking
+	}{
+		{"found", newTestRouter(), "GET", "/a", 200, "", "get"},
+		{"found in later node", newTestRouter(), "DELETE", "/a", 200, "", "delete"},
+		{"not found", newTestRouter(), "GET", "/b", 404, "", ""},
+		{"method not allowed", newTestRouter(), "PUT", "/a", 405, "GET, HEAD, OPTIONS, POST, DELETE", ""},
+		{"custom not found", custom, "GET", "/b", 404, "", "custom not found /b"},
+		{"custom method not allowed", custom, "PUT", "/a", 405, "GET, HEAD, OPTIONS, POST, DELETE", "custom not allowed, allow GET, HEAD, OPTIONS, POST, DELETE"},
+	}
+
+	for _, test := range tests {
+
+		recorder := httptest.NewRecorder()
+
+		test.router.ServeHTTP(recorder, httptest.NewRequest(test.method, test.path, nil))
+
+		if recorder.Code != test.code {
+			t.Errorf("%s code got %d, expect %d", test.name, recorder.Code, test.code)
+		}
+
+		if got := recorder.Header().Get("Allow"); got != test.allow {
+			t.Errorf("%s allow got %q, expect %q", test.name, got, test.allow)
+		}
+
+		if test.body != "" && recorder.Body.String() != test.body {
+			t.Errorf("%s body got %q, expect %q", test.name, recorder.Body.String(), test.body)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package gsweb
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gsdocker/gserrors"
+	"github.com/gsdocker/gslogger"
+)
+
+// securityHeaderDisabled the header value disabling the header
+const securityHeaderDisabled = "-"
+
+// cspNoncePlaceholder the placeholder replaced by the request's nonce in
+// Content-Security-Policy header
+const cspNoncePlaceholder = "{nonce}"
+
+// defaultContentSecurityPolicy the default Content-Security-Policy, inline
+// scripts and styles must carry the request's nonce, see Context.CSPNonce
+const defaultContentSecurityPolicy = "default-src 'self'; script-src 'self' 'nonce-{nonce}'; style-src 'self' 'nonce-{nonce}'"
+
+// SecurityHeadersConfig the security headers chain node config, empty fields
+// use the default value, "-" disables the header
+type SecurityHeadersConfig struct {
+	StrictTransportSecurity string   // HSTS, only sent on https requests, default max-age=31536000; includeSubDomains
+	ContentTypeOptions      string   // X-Content-Type-Options, default nosniff
+	FrameOptions            string   // X-Frame-Options, default DENY
+	ReferrerPolicy          string   // Referrer-Policy, default strict-origin-when-cross-origin
+	PermissionsPolicy       string   // Permissions-Policy, default disabled
+	ContentSecurityPolicy   string   // Content-Security-Policy, {nonce} is replaced by the request's nonce, default defaultContentSecurityPolicy
+	TrustedProxies          []string // trusted proxy ips or CIDRs, X-Forwarded-Proto is honored for requests from them
+}
+
+// securityOverride the headers overridden under uri prefix
+type securityOverride struct {
+	prefix  string            // uri prefix
+	headers map[string]string // overridden headers, "-" removes the header
+}
+
+// SecurityHeaders the chain node setting security response headers
+type SecurityHeaders struct {
+	gslogger.Log                     // Mixin log APIs
+	mutex        sync.RWMutex        // overrides guard
+	headers      map[string]string   // default headers
+	overrides    []*securityOverride // overrides ordered by prefix length
+	proxies      trustedProxies      // trusted proxies
+}
+
+func securityHeaderValue(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
+}
+
+// NewSecurityHeaders create security headers chain node
+func NewSecurityHeaders(config SecurityHeadersConfig) *SecurityHeaders {
+	return &SecurityHeaders{
+		Log: gslogger.Get("security"),
+		headers: map[string]string{
+			"Strict-Transport-Security": securityHeaderValue(config.StrictTransportSecurity, "max-age=31536000; includeSubDomains"),
+			"X-Content-Type-Options":    securityHeaderValue(config.ContentTypeOptions, "nosniff"),
+			"X-Frame-Options":           securityHeaderValue(config.FrameOptions, "DENY"),
+			"Referrer-Policy":           securityHeaderValue(config.ReferrerPolicy, "strict-origin-when-cross-origin"),
+			"Permissions-Policy":        securityHeaderValue(config.PermissionsPolicy, securityHeaderDisabled),
+			"Content-Security-Policy":   securityHeaderValue(config.ContentSecurityPolicy, defaultContentSecurityPolicy),
+		},
+		proxies: parseTrustedProxies(config.TrustedProxies),
+	}
+}
+
+// Override override header for requests under uri prefix, "-" removes the
+// header, the override of the longest matched prefix wins
+func (security *SecurityHeaders) Override(prefix string, header string, value string) *SecurityHeaders {
+
+	gserrors.Assert(value != "", "security header %s override under %s is empty, use \"-\" to remove it", header, prefix)
+
+	security.mutex.Lock()
+	defer security.mutex.Unlock()
+
+	header = http.CanonicalHeaderKey(header)
+
+	for _, override := range security.overrides {
+		if override.prefix == prefix {
+			override.headers[header] = value
+			return security
+		}
+	}
+
+	security.overrides = append(security.overrides, &securityOverride{
+		prefix:  prefix,
+		headers: map[string]string{header: value},
+	})
+
+	sort.SliceStable(security.overrides, func(i, j int) bool {
+		return len(security.overrides[i].prefix) < len(security.overrides[j].prefix)
+	})
+
+	return security
+}
+
+// resolve get the headers applied to uri
+func (security *SecurityHeaders) resolve(uri string) map[string]string {
+	security.mutex.RLock()
+	defer security.mutex.RUnlock()
+
+	headers := security.headers
+
+	copied := false
+
+	for _, override := range security.overrides {
+
+		if !matchPrefix(uri, override.prefix) {
+			continue
+		}
+
+		if !copied {
+			headers = make(map[string]string, len(security.headers))
+
+			for name, value := range security.headers {
+				headers[name] = value
+			}
+
+			copied = true
+		}
+
+		for name, value := range override.headers {
+			headers[name] = value
+		}
+	}
+
+	return headers
+}
+
+// HandleUnknown implement Unknown interface
+func (security *SecurityHeaders) HandleUnknown(context *Context) error {
+
+	header := context.Response().Header()
+
+	for name, value := range security.resolve(context.RequestURI()) {
+
+		if value == securityHeaderDisabled {
+			continue
+		}
+
+		if name == "Strict-Transport-Security" && !security.https(context.Request()) {
+			continue
+		}
+
+		if name == "Content-Security-Policy" && strings.Contains(value, cspNoncePlaceholder) {
+			value = strings.Replace(value, cspNoncePlaceholder, context.CSPNonce(), -1)
+		}
+
+		header.Set(name, value)
+	}
+
+	return context.Forward()
+}
+
+// https check if request is sent over https, the X-Forwarded-Proto is honored
+// for requests from trusted proxies, e.g. TLS terminating proxy
+func (security *SecurityHeaders) https(request *http.Request) bool {
+
+	if request.TLS != nil {
+		return true
+	}
+
+	return security.proxies.forwarded(request) && strings.EqualFold(firstHeaderValue(request, "X-Forwarded-Proto"), "https")
+}
+
+// CSPNonce get the request's Content-Security-Policy nonce, use it as inline
+// script's nonce attribute, e.g. <script nonce="{{.Nonce}}">
+func (context *Context) CSPNonce() string {
+
+	if context.cspNonce == "" {
+		buff := make([]byte, 16)
+
+		if _, err := rand.Read(buff); err != nil {
+			panic(err)
+		}
+
+		// url safe alphabet, html/template escapes + in attribute values
+		context.cspNonce = base64.RawURLEncoding.EncodeToString(buff)
+	}
+
+	return context.cspNonce
+}
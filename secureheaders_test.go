@@ -0,0 +1,173 @@
+package gsweb
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type securityHeadersTestHandler struct{}
+
+func (securityHeadersTestHandler) HandleGet(context *Context) error {
+	// the nonce must be stable within request
+	return context.Text(200, "%s %s", context.CSPNonce(), context.CSPNonce())
+}
+
+func TestSecurityHeaders(t *testing.T) {
+
+	router := newRouter()
+	router.ChainHandle("security", NewSecurityHeaders(SecurityHeadersConfig{
+		FrameOptions:   "SAMEORIGIN",
+		TrustedProxies: []string{"10.0.0.0/8"},
+	}).
+		Override("/embed", "X-Frame-Options", "-").
+		Override("/docs", "Content-Security-Policy", "default-src 'self' 'nonce-{nonce}'").
+		Override("/docs/raw", "Content-Security-Policy", "-"))
+
+	uri := NewURIHandler()
+	uri.Handle("/a", securityHeadersTestHandler{})
+	uri.Handle("/embed", securityHeadersTestHandler{})
+	uri.Handle("/docs", securityHeadersTestHandler{})
+	uri.Handle("/docs/raw", securityHeadersTestHandler{})
+	router.ChainHandle("uri", uri)
+
+	do := func(path string, modify func(request *http.Request)) *httptest.ResponseRecorder {
+
+		request := httptest.NewRequest("GET", path, nil)
+
+		if modify != nil {
+			modify(request)
+		}
+
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	overTLS := func(request *http.Request) { request.TLS = &tls.ConnectionState{} }
+
+	forwarded := func(remote string, proto string) func(request *http.Request) {
+		return func(request *http.Request) {
+			request.RemoteAddr = remote
+			request.Header.Set("X-Forwarded-Proto", proto)
+		}
+	}
+
+	const hsts = "max-age=31536000; includeSubDomains"
+
+	tests := []struct {
+		name    string
+		path    string
+		modify  func(request *http.Request)
+		headers map[string]string // expected headers, "-" expects header absent, {nonce} the response nonce
+	}{
+		{
+			"defaults",
+			"/a",
+			nil,
+			map[string]string{
+				"X-Content-Type-Options":    "nosniff",
+				"X-Frame-Options":           "SAMEORIGIN",
+				"Referrer-Policy":           "strict-origin-when-cross-origin",
+				"Permissions-Policy":        "-",
+				"Content-Security-Policy":   "default-src 'self'; script-src 'self' 'nonce-{nonce}'; style-src 'self' 'nonce-{nonce}'",
+				"Strict-Transport-Security": "-",
+			},
+		},
+		{
+			"hsts over tls",
+			"/a",
+			overTLS,
+			map[string]string{"Strict-Transport-Security": hsts},
+		},
+		{
+			"hsts from trusted tls proxy",
+			"/a",
+			forwarded("10.0.0.1:1", "https"),
+			map[string]string{"Strict-Transport-Security": hsts},
+		},
+		{
+			"no hsts from trusted plain proxy",
+			"/a",
+			forwarded("10.0.0.1:1", "http"),
+			map[string]string{"Strict-Transport-Security": "-"},
+		},
+		{
+			"no hsts from untrusted proxy",
+			"/a",
+			forwarded("192.0.2.1:1", "https"),
+			map[string]string{"Strict-Transport-Security": "-"},
+		},
+		{
+			"override removes header",
+			"/embed",
+			nil,
+			map[string]string{"X-Frame-Options": "-", "X-Content-Type-Options": "nosniff"},
+		},
+		{
+			"prefix override",
+			"/docs",
+			nil,
+			map[string]string{"Content-Security-Policy": "default-src 'self' 'nonce-{nonce}'", "X-Frame-Options": "SAMEORIGIN"},
+		},
+		{
+			"longest prefix override wins",
+			"/docs/raw",
+			nil,
+			map[string]string{"Content-Security-Policy": "-"},
+		},
+	}
+
+	for _, test := range tests {
+
+		recorder := do(test.path, test.modify)
+
+		nonce := strings.Fields(recorder.Body.String())[0]
+
+		for name, expect := range test.headers {
+
+			got, ok := recorder.Header()[name]
+
+			if expect == "-" {
+				if ok {
+					t.Errorf("%s header %s got %v, expect absent", test.name, name, got)
+				}
+
+				continue
+			}
+
+			expect = strings.Replace(expect, cspNoncePlaceholder, nonce, -1)
+
+			if value := recorder.Header().Get(name); value != expect {
+				t.Errorf("%s header %s got %q, expect %q", test.name, name, value, expect)
+			}
+		}
+	}
+
+	// the nonce is stable within request, and differs across requests
+	first := strings.Fields(do("/a", nil).Body.String())
+	second := strings.Fields(do("/a", nil).Body.String())
+
+	if first[0] == "" || first[0] != first[1] {
+		t.Errorf("nonce not stable within request : %v", first)
+	}
+
+	if first[0] == second[0] {
+		t.Errorf("nonce reused across requests : %s", first[0])
+	}
+}
+
+func TestSecurityHeadersEmptyOverride(t *testing.T) {
+
+	defer func() {
+		if recover() == nil {
+			t.Error("empty override value must be rejected")
+		}
+	}()
+
+	NewSecurityHeaders(SecurityHeadersConfig{}).Override("/a", "X-Frame-Options", "")
+}
@@ -0,0 +1,320 @@
+package gsweb
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gsdocker/gslogger"
+)
+
+const (
+	flashKey           = "_flash"
+	sessionTouchPeriod = time.Minute
+)
+
+// SessionState the session data persisted by SessionStore, values stored
+// by gob encoding stores must be registered by gob.Register
+type SessionState struct {
+	ID       string                 // session id
+	Values   map[string]interface{} // session values
+	Created  time.Time              // session create time
+	Accessed time.Time              // session last access time
+}
+
+// SessionStore the session persistent store
+type SessionStore interface {
+	// Load load session state by the key read from cookie, returns nil if not found
+	Load(key string) (*SessionState, error)
+	// Save save session state expires after ttl, returns the key written into cookie
+	Save(state *SessionState, ttl time.Duration) (string, error)
+	// Delete delete session state by the key read from cookie
+	Delete(key string) error
+}
+
+// Session the request's session object
+type Session struct {
+	mutex     sync.Mutex    // state guard
+	state     *SessionState // session state
+	key       string        // the key loaded from cookie
+	modified  bool          // indicate if values modified
+	rotated   bool          // indicate if session id should be rotated
+	destroyed bool          // indicate if session should be destroyed
+}
+
+// ID get session id
+func (session *Session) ID() string {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	return session.state.ID
+}
+
+// Get get session value by key, returns nil if not exists
+func (session *Session) Get(key string) interface{} {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	return session.state.Values[key]
+}
+
+// Set set session value
+func (session *Session) Set(key string, value interface{}) {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	session.state.Values[key] = value
+	session.modified = true
+}
+
+// Delete delete session value
+func (session *Session) Delete(key string) {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	delete(session.state.Values, key)
+	session.modified = true
+}
+
+// Flash add flash message read by next request's Flashes call
+func (session *Session) Flash(message string) {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	flashes, _ := session.state.Values[flashKey].([]string)
+
+	session.state.Values[flashKey] = append(flashes, message)
+	session.modified = true
+}
+
+// Flashes get and clear flash messages
+func (session *Session) Flashes() []string {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	flashes, ok := session.state.Values[flashKey].([]string)
+
+	if ok {
+		delete(session.state.Values, flashKey)
+		session.modified = true
+	}
+
+	return flashes
+}
+
+// Rotate renew session id keeping the values, call it after login or
+// privilege change to prevent session fixation
+func (session *Session) Rotate() {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	session.state.ID = newSessionID()
+	session.rotated = true
+	session.modified = true
+}
+
+// Destroy delete session from store and clear session cookie
+func (session *Session) Destroy() {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	session.state.Values = make(map[string]interface{})
+	session.destroyed = true
+}
+
+// SessionConfig the session chain node config
+type SessionConfig struct {
+	CookieName  string        // session cookie name, default gsweb_session
+	Path        string        // session cookie path, default /
+	Domain      string        // session cookie domain
+	Secure      bool          // session cookie secure flag
+	SameSite    http.SameSite // session cookie SameSite attribute, default Lax
+	IdleTimeout time.Duration // session expires after idle timeout, default 30 minutes
+	MaxLifetime time.Duration // session absolute expiry, default 24 hours
+}
+
+// Sessions the session management chain node
+type Sessions struct {
+	gslogger.Log               // Mixin log APIs
+	store        SessionStore  // session store
+	config       SessionConfig // session config
+}
+
+// NewSessions create session chain node
+func NewSessions(store SessionStore, config SessionConfig) *Sessions {
+
+	if config.CookieName == "" {
+		config.CookieName = "gsweb_session"
+	}
+
+	if config.Path == "" {
+		config.Path = "/"
+	}
+
+	if config.SameSite == 0 {
+		config.SameSite = http.SameSiteLaxMode
+	}
+
+	if config.IdleTimeout == 0 {
+		config.IdleTimeout = 30 * time.Minute
+	}
+
+	if config.MaxLifetime == 0 {
+		config.MaxLifetime = 24 * time.Hour
+	}
+
+	return &Sessions{
+		Log:    gslogger.Get("session"),
+		store:  store,
+		config: config,
+	}
+}
+
+// HandleUnknown implement Unknown interface
+func (sessions *Sessions) HandleUnknown(context *Context) error {
+
+	session := sessions.load(context)
+
+	context.session = session
+
+	context.BeforeWrite(func() {
+		sessions.save(context, session)
+	})
+
+	return context.Forward()
+}
+
+func newSessionID() string {
+	buff := make([]byte, 32)
+
+	if _, err := rand.Read(buff); err != nil {
+		panic(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buff)
+}
+
+func newSessionState() *SessionState {
+	now := time.Now()
+
+	return &SessionState{
+		ID:       newSessionID(),
+		Values:   make(map[string]interface{}),
+		Created:  now,
+		Accessed: now,
+	}
+}
+
+func (sessions *Sessions) load(context *Context) *Session {
+
+	cookie, err := context.Request().Cookie(sessions.config.CookieName)
+
+	if err != nil || cookie.Value == "" {
+		return &Session{state: newSessionState()}
+	}
+
+	state, err := sessions.store.Load(cookie.Value)
+
+	if err != nil {
+		sessions.W("load session error : %s", err)
+	}
+
+	if state == nil {
+		return &Session{state: newSessionState()}
+	}
+
+	now := time.Now()
+
+	if now.Sub(state.Accessed) > sessions.config.IdleTimeout || now.Sub(state.Created) > sessions.config.MaxLifetime {
+
+		sessions.D("session %s expired", state.ID)
+
+		if err := sessions.store.Delete(cookie.Value); err != nil {
+			sessions.W("delete expired session error : %s", err)
+		}
+
+		return &Session{state: newSessionState()}
+	}
+
+	if state.Values == nil {
+		state.Values = make(map[string]interface{})
+	}
+
+	return &Session{state: state, key: cookie.Value}
+}
+
+func (sessions *Sessions) save(context *Context, session *Session) {
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	if session.destroyed {
+		if session.key != "" {
+			if err := sessions.store.Delete(session.key); err != nil {
+				sessions.W("delete session error : %s", err)
+			}
+
+			sessions.setCookie(context, "", -1)
+		}
+
+		return
+	}
+
+	// don't create session for client never set values
+	if session.key == "" && len(session.state.Values) == 0 {
+		return
+	}
+
+	now := time.Now()
+
+	// unmodified session only refresh access time once per touch period
+	if session.key != "" && !session.modified && now.Sub(session.state.Accessed) < sessionTouchPeriod {
+		return
+	}
+
+	if session.rotated && session.key != "" {
+		if err := sessions.store.Delete(session.key); err != nil {
+			sessions.W("delete rotated session error : %s", err)
+		}
+	}
+
+	session.state.Accessed = now
+
+	ttl := sessions.config.IdleTimeout
+
+	if remain := session.state.Created.Add(sessions.config.MaxLifetime).Sub(now); remain < ttl {
+		ttl = remain
+	}
+
+	key, err := sessions.store.Save(session.state, ttl)
+
+	if err != nil {
+		sessions.E("save session %s error : %s", session.state.ID, err)
+		return
+	}
+
+	session.key = key
+
+	sessions.setCookie(context, key, int(ttl/time.Second))
+}
+
+func (sessions *Sessions) setCookie(context *Context, value string, maxAge int) {
+	http.SetCookie(context.Response(), &http.Cookie{
+		Name:     sessions.config.CookieName,
+		Value:    value,
+		Path:     sessions.config.Path,
+		Domain:   sessions.config.Domain,
+		MaxAge:   maxAge,
+		Secure:   sessions.config.Secure,
+		HttpOnly: true,
+		SameSite: sessions.config.SameSite,
+	})
+}
+
+// Session get the request's session, returns nil if no Sessions chain node
+// processed the request
+func (context *Context) Session() *Session {
+	return context.session
+}
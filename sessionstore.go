@@ -0,0 +1,372 @@
+package gsweb
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/gob"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gsdocker/gserrors"
+)
+
+// storedSession the session state with expire time
+type storedSession struct {
+	State   *SessionState // session state
+	Expires time.Time     // expire time
+}
+
+func copySessionState(state *SessionState) *SessionState {
+	values := make(map[string]interface{}, len(state.Values))
+
+	for k, v := range state.Values {
+		values[k] = v
+	}
+
+	clone := *state
+	clone.Values = values
+
+	return &clone
+}
+
+// MemorySessionStore the in-memory session store evicting least recently used
+// sessions when exceed capacity
+type MemorySessionStore struct {
+	mutex    sync.Mutex               // store guard
+	capacity int                      // max session count
+	lru      *list.List               // sessions ordered by access, front is the most recent
+	sessions map[string]*list.Element // sessions indexed by id
+}
+
+// NewMemorySessionStore create in-memory session store holding at most
+// capacity sessions
+func NewMemorySessionStore(capacity int) *MemorySessionStore {
+	return &MemorySessionStore{
+		capacity: capacity,
+		lru:      list.New(),
+		sessions: make(map[string]*list.Element),
+	}
+}
+
+// Load implement SessionStore
+func (store *MemorySessionStore) Load(key string) (*SessionState, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	element, ok := store.sessions[key]
+
+	if !ok {
+		return nil, nil
+	}
+
+	stored := element.Value.(*storedSession)
+
+	if time.Now().After(stored.Expires) {
+		store.lru.Remove(element)
+		delete(store.sessions, key)
+		return nil, nil
+	}
+
+	store.lru.MoveToFront(element)
+
+	return copySessionState(stored.State), nil
+}
+
+// Save implement SessionStore
+func (store *MemorySessionStore) Save(state *SessionState, ttl time.Duration) (string, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	stored := &storedSession{State: copySessionState(state), Expires: time.Now().Add(ttl)}
+
+	if element, ok := store.sessions[state.ID]; ok {
+		element.Value = stored
+		store.lru.MoveToFront(element)
+		return state.ID, nil
+	}
+
+	store.sessions[state.ID] = store.lru.PushFront(stored)
+
+	for store.capacity > 0 && store.lru.Len() > store.capacity {
+		oldest := store.lru.Back()
+		store.lru.Remove(oldest)
+		delete(store.sessions, oldest.Value.(*storedSession).State.ID)
+	}
+
+	return state.ID, nil
+}
+
+// Delete implement SessionStore
+func (store *MemorySessionStore) Delete(key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if element, ok := store.sessions[key]; ok {
+		store.lru.Remove(element)
+		delete(store.sessions, key)
+	}
+
+	return nil
+}
+
+// FileSessionStore the on-disk session store saving each session as a gob
+// encoded file named by session id, expired files are purged periodically
+type FileSessionStore struct {
+	dir       string     // session files directory
+	mutex     sync.Mutex // purge guard
+	lastPurge time.Time  // last purge time
+}
+
+// NewFileSessionStore create on-disk session store under dir
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, gserrors.Newf(err, "create session dir %s error", dir)
+	}
+
+	return &FileSessionStore{dir: dir, lastPurge: time.Now()}, nil
+}
+
+// path get session file path, returns false if the key is not valid session id
+func (store *FileSessionStore) path(key string) (string, bool) {
+
+	if key == "" || strings.Trim(key, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_") != "" {
+		return "", false
+	}
+
+	return filepath.Join(store.dir, key+".session"), true
+}
+
+// Load implement SessionStore
+func (store *FileSessionStore) Load(key string) (*SessionState, error) {
+
+	path, ok := store.path(key)
+
+	if !ok {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, gserrors.Newf(err, "open session file %s error", path)
+	}
+
+	defer file.Close()
+
+	var stored storedSession
+
+	if err := gob.NewDecoder(file).Decode(&stored); err != nil {
+		return nil, gserrors.Newf(err, "decode session file %s error", path)
+	}
+
+	if time.Now().After(stored.Expires) {
+		os.Remove(path)
+		return nil, nil
+	}
+
+	return stored.State, nil
+}
+
+// Save implement SessionStore
+func (store *FileSessionStore) Save(state *SessionState, ttl time.Duration) (string, error) {
+
+	path, _ := store.path(state.ID)
+
+	var buff bytes.Buffer
+
+	if err := gob.NewEncoder(&buff).Encode(&storedSession{State: state, Expires: time.Now().Add(ttl)}); err != nil {
+		return "", gserrors.Newf(err, "encode session %s error", state.ID)
+	}
+
+	// unique temp file so concurrent saves of one session don't clobber each other
+	temp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+
+	if err != nil {
+		return "", gserrors.Newf(err, "create session temp file of %s error", path)
+	}
+
+	_, err = temp.Write(buff.Bytes())
+
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		os.Remove(temp.Name())
+		return "", gserrors.Newf(err, "write session file %s error", temp.Name())
+	}
+
+	if err := os.Rename(temp.Name(), path); err != nil {
+		os.Remove(temp.Name())
+		return "", gserrors.Newf(err, "rename session file %s error", temp.Name())
+	}
+
+	store.mutex.Lock()
+
+	if time.Since(store.lastPurge) > 10*time.Minute {
+		store.lastPurge = time.Now()
+		go store.Purge()
+	}
+
+	store.mutex.Unlock()
+
+	return state.ID, nil
+}
+
+// Delete implement SessionStore
+func (store *FileSessionStore) Delete(key string) error {
+
+	path, ok := store.path(key)
+
+	if !ok {
+		return nil
+	}
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return gserrors.Newf(err, "remove session file %s error", path)
+	}
+
+	return nil
+}
+
+// Purge remove expired session files
+func (store *FileSessionStore) Purge() error {
+
+	files, err := filepath.Glob(filepath.Join(store.dir, "*.session"))
+
+	if err != nil {
+		return gserrors.Newf(err, "list session files error")
+	}
+
+	for _, path := range files {
+		if _, err := store.Load(strings.TrimSuffix(filepath.Base(path), ".session")); err != nil {
+			os.Remove(path)
+		}
+	}
+
+	return nil
+}
+
+// CookieSessionStore the session store saving session state in the cookie
+// itself, encrypted by AES-GCM and signed by HMAC-SHA256
+type CookieSessionStore struct {
+	hashKey []byte      // HMAC key
+	aead    cipher.AEAD // encryption
+}
+
+// NewCookieSessionStore create cookie session store, the blockKey must be
+// 16, 24 or 32 bytes selecting AES-128, AES-192 or AES-256
+func NewCookieSessionStore(hashKey []byte, blockKey []byte) (*CookieSessionStore, error) {
+
+	if len(hashKey) == 0 {
+		return nil, gserrors.Newf(nil, "cookie session store expect hash key")
+	}
+
+	block, err := aes.NewCipher(blockKey)
+
+	if err != nil {
+		return nil, gserrors.Newf(err, "create cookie session cipher error")
+	}
+
+	aead, err := cipher.NewGCM(block)
+
+	if err != nil {
+		return nil, gserrors.Newf(err, "create cookie session cipher error")
+	}
+
+	return &CookieSessionStore{hashKey: hashKey, aead: aead}, nil
+}
+
+func (store *CookieSessionStore) sign(content string) string {
+	mac := hmac.New(sha256.New, store.hashKey)
+	mac.Write([]byte(content))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Load implement SessionStore
+func (store *CookieSessionStore) Load(key string) (*SessionState, error) {
+
+	dot := strings.LastIndexByte(key, '.')
+
+	if dot == -1 {
+		return nil, nil
+	}
+
+	content, signature := key[:dot], key[dot+1:]
+
+	if !hmac.Equal([]byte(signature), []byte(store.sign(content))) {
+		return nil, gserrors.Newf(nil, "invalid session cookie signature")
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(content)
+
+	if err != nil || len(sealed) < store.aead.NonceSize() {
+		return nil, gserrors.Newf(err, "invalid session cookie")
+	}
+
+	nonce, ciphertext := sealed[:store.aead.NonceSize()], sealed[store.aead.NonceSize():]
+
+	plaintext, err := store.aead.Open(nil, nonce, ciphertext, nil)
+
+	if err != nil {
+		return nil, gserrors.Newf(err, "decrypt session cookie error")
+	}
+
+	var stored storedSession
+
+	if err := gob.NewDecoder(bytes.NewReader(plaintext)).Decode(&stored); err != nil {
+		return nil, gserrors.Newf(err, "decode session cookie error")
+	}
+
+	if time.Now().After(stored.Expires) {
+		return nil, nil
+	}
+
+	return stored.State, nil
+}
+
+// Save implement SessionStore
+func (store *CookieSessionStore) Save(state *SessionState, ttl time.Duration) (string, error) {
+
+	var buff bytes.Buffer
+
+	if err := gob.NewEncoder(&buff).Encode(&storedSession{State: state, Expires: time.Now().Add(ttl)}); err != nil {
+		return "", gserrors.Newf(err, "encode session %s error", state.ID)
+	}
+
+	nonce := make([]byte, store.aead.NonceSize())
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", gserrors.Newf(err, "generate session cookie nonce error")
+	}
+
+	content := base64.RawURLEncoding.EncodeToString(store.aead.Seal(nonce, nonce, buff.Bytes(), nil))
+
+	key := content + "." + store.sign(content)
+
+	if len(key) > 4000 {
+		return "", gserrors.Newf(nil, "session %s too large for cookie : %d bytes", state.ID, len(key))
+	}
+
+	return key, nil
+}
+
+// Delete implement SessionStore
+func (store *CookieSessionStore) Delete(key string) error {
+	return nil
+}
@@ -0,0 +1,145 @@
+package gsweb
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSessionStores(t *testing.T) {
+
+	fileStore, err := NewFileSessionStore(t.TempDir())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookieStore, err := NewCookieSessionStore([]byte("hash-key"), []byte("0123456789abcdef"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]SessionStore{
+		"memory": NewMemorySessionStore(16),
+		"file":   fileStore,
+		"cookie": cookieStore,
+	}
+
+	for name, store := range stores {
+
+		state := &SessionState{ID: "abc-123", Values: map[string]interface{}{"user": "alice"}, Created: time.Now()}
+
+		key, err := store.Save(state, time.Minute)
+
+		if err != nil {
+			t.Fatalf("%s save error : %s", name, err)
+		}
+
+		loaded, err := store.Load(key)
+
+		if err != nil || loaded == nil || loaded.ID != state.ID || loaded.Values["user"] != "alice" {
+			t.Fatalf("%s load got %v %v", name, loaded, err)
+		}
+
+		expired, err := store.Save(state, -time.Second)
+
+		if err != nil {
+			t.Fatalf("%s save expired error : %s", name, err)
+		}
+
+		if loaded, _ := store.Load(expired); loaded != nil {
+			t.Errorf("%s load expired session %v", name, loaded)
+		}
+
+		if loaded, _ := store.Load("missing"); loaded != nil {
+			t.Errorf("%s load missing session %v", name, loaded)
+		}
+	}
+}
+
+func TestCookieSessionStoreTampered(t *testing.T) {
+
+	store, _ := NewCookieSessionStore([]byte("hash-key"), []byte("0123456789abcdef"))
+
+	key, _ := store.Save(&SessionState{ID: "id", Values: map[string]interface{}{}}, time.Minute)
+
+	dot := strings.LastIndexByte(key, '.')
+
+	flipped := "A"
+
+	if key[0] == 'A' {
+		flipped = "B"
+	}
+
+	tampered := []string{
+		flipped + key[1:],
+		key[:dot] + ".invalid",
+		"no-signature",
+	}
+
+	for _, key := range tampered {
+		if loaded, _ := store.Load(key); loaded != nil {
+			t.Errorf("load tampered cookie %s got %v", key, loaded)
+		}
+	}
+
+	other, _ := NewCookieSessionStore([]byte("other-key"), []byte("0123456789abcdef"))
+
+	if loaded, _ := other.Load(key); loaded != nil {
+		t.Errorf("load cookie signed by other key got %v", loaded)
+	}
+}
+
+func TestFileSessionStoreConcurrentSave(t *testing.T) {
+
+	dir := t.TempDir()
+
+	store, _ := NewFileSessionStore(dir)
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, 32)
+
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			state := &SessionState{ID: "shared", Values: map[string]interface{}{"n": i}}
+
+			if _, err := store.Save(state, time.Minute); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if loaded, err := store.Load("shared"); err != nil || loaded == nil {
+		t.Fatalf("load got %v %v", loaded, err)
+	}
+
+	temps, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+
+	if len(temps) != 0 {
+		t.Errorf("temp files left %v", temps)
+	}
+
+	if _, ok := store.path("../escape"); ok {
+		t.Error("path accept invalid session id")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "shared.session")); err != nil {
+		t.Error(err)
+	}
+}
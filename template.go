@@ -0,0 +1,287 @@
+package gsweb
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gsdocker/gserrors"
+	"github.com/gsdocker/gslogger"
+)
+
+// TemplateEngine the html/template engine loading templates from directory.
+//
+// Files under layouts/ are layouts, files under partials/ are partials, the
+// others are pages. Each template is named by its path relative to the
+// directory without extension, e.g. layouts/main, partials/header, users/show.
+//
+// Every page is parsed with all layouts and partials, so layouts can declare
+// {{block "content" .}}{{end}} blocks overridden by the page's
+// {{define "content"}}...{{end}}, and any template can include partials by
+// {{template "partials/header" .}}
+type TemplateEngine struct {
+	gslogger.Log                               // Mixin log APIs
+	dir          string                        // template root directory
+	extension    string                        // template file extension
+	layout       string                        // default layout name
+	funcs        template.FuncMap              // customer func map
+	reload       bool                          // reload on file change flag
+	mutex        sync.RWMutex                  // pages guard
+	pages        map[string]*template.Template // parsed pages
+	signature    string                        // the directory state when pages parsed
+}
+
+// NewTemplateEngine create new template engine loading *.html files from dir
+func NewTemplateEngine(dir string) *TemplateEngine {
+	return &TemplateEngine{
+		Log:       gslogger.Get("template"),
+		dir:       dir,
+		extension: ".html",
+		funcs:     make(template.FuncMap),
+	}
+}
+
+// SetExtension set template file extension, default is .html
+func (engine *TemplateEngine) SetExtension(extension string) {
+	engine.extension = extension
+}
+
+// SetLayout set the default layout name used by Render, e.g. "main" for
+// layouts/main.html, empty string render pages without layout
+func (engine *TemplateEngine) SetLayout(layout string) {
+	engine.layout = layout
+}
+
+// Funcs add customer functions to template func map, must be called before Load
+func (engine *TemplateEngine) Funcs(funcs template.FuncMap) {
+	for name, f := range funcs {
+		engine.funcs[name] = f
+	}
+}
+
+// EnableReload set flag, true reload templates when files changed which is
+// used in development mode, otherwise parsed templates are cached
+func (engine *TemplateEngine) EnableReload(flag bool) {
+	engine.reload = flag
+}
+
+// Load parse all templates under the directory
+func (engine *TemplateEngine) Load() error {
+
+	engine.D("load templates from %s", engine.dir)
+
+	var shared, pages []string
+
+	signature, err := engine.walk(func(name string) {
+		if strings.HasPrefix(name, "layouts/") || strings.HasPrefix(name, "partials/") {
+			shared = append(shared, name)
+		} else {
+			pages = append(pages, name)
+		}
+	})
+
+	if err != nil {
+		return err
+	}
+
+	base := template.New("").Funcs(engine.funcs)
+
+	for _, name := range shared {
+		if err := engine.parse(base, name); err != nil {
+			return err
+		}
+	}
+
+	parsed := make(map[string]*template.Template, len(pages))
+
+	for _, name := range pages {
+
+		page, err := base.Clone()
+
+		if err != nil {
+			return gserrors.Newf(err, "clone template set for %s error", name)
+		}
+
+		if err := engine.parse(page, name); err != nil {
+			return err
+		}
+
+		parsed[name] = page
+	}
+
+	engine.mutex.Lock()
+	engine.pages = parsed
+	engine.signature = signature
+	engine.mutex.Unlock()
+
+	engine.D("load templates from %s -- success", engine.dir)
+
+	return nil
+}
+
+func (engine *TemplateEngine) parse(set *template.Template, name string) error {
+
+	content, err := os.ReadFile(filepath.Join(engine.dir, filepath.FromSlash(name)+engine.extension))
+
+	if err != nil {
+		return gserrors.Newf(err, "read template %s error", name)
+	}
+
+	if _, err := set.New(name).Parse(string(content)); err != nil {
+		return gserrors.Newf(err, "parse template %s error", name)
+	}
+
+	return nil
+}
+
+// walk call f with every template name, returns the directory state signature
+func (engine *TemplateEngine) walk(f func(name string)) (string, error) {
+
+	var states []string
+
+	err := filepath.Walk(engine.dir, func(path string, info os.FileInfo, err error) error {
+
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || filepath.Ext(path) != engine.extension {
+			return nil
+		}
+
+		rel, err := filepath.Rel(engine.dir, path)
+
+		if err != nil {
+			return err
+		}
+
+		name := strings.TrimSuffix(filepath.ToSlash(rel), engine.extension)
+
+		if f != nil {
+			f(name)
+		}
+
+		states = append(states, fmt.Sprintf("%s:%d:%d", name, info.ModTime().UnixNano(), info.Size()))
+
+		return nil
+	})
+
+	if err != nil {
+		return "", gserrors.Newf(err, "walk template dir %s error", engine.dir)
+	}
+
+	sort.Strings(states)
+
+	return strings.Join(states, "|"), nil
+}
+
+// lookup get the parsed page, reload templates if files changed in reload mode
+func (engine *TemplateEngine) lookup(name string) (*template.Template, error) {
+
+	engine.mutex.RLock()
+	pages, signature := engine.pages, engine.signature
+	engine.mutex.RUnlock()
+
+	if pages == nil {
+		if err := engine.Load(); err != nil {
+			return nil, err
+		}
+	} else if engine.reload {
+
+		current, err := engine.walk(nil)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if current != signature {
+			engine.I("template files changed, reload templates")
+
+			if err := engine.Load(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	engine.mutex.RLock()
+	page, ok := engine.pages[name]
+	engine.mutex.RUnlock()
+
+	if !ok {
+		return nil, gserrors.Newf(nil, "template %s not found", name)
+	}
+
+	return page, nil
+}
+
+// Render execute page with the default layout
+func (engine *TemplateEngine) Render(writer io.Writer, name string, data interface{}) error {
+	return engine.RenderLayout(writer, engine.layout, name, data)
+}
+
+// RenderLayout execute page with layout, empty layout execute the page only
+func (engine *TemplateEngine) RenderLayout(writer io.Writer, layout string, name string, data interface{}) error {
+
+	page, err := engine.lookup(name)
+
+	if err != nil {
+		return err
+	}
+
+	entry := name
+
+	if layout != "" {
+		entry = "layouts/" + layout
+
+		if page.Lookup(entry) == nil {
+			return gserrors.Newf(nil, "layout %s not found", layout)
+		}
+	}
+
+	if err := page.ExecuteTemplate(writer, entry, data); err != nil {
+		return gserrors.Newf(err, "execute template %s error", name)
+	}
+
+	return nil
+}
+
+// SetTemplates set the template engine used by Context.Render
+func (router *Router) SetTemplates(engine *TemplateEngine) {
+	router.templates = engine
+}
+
+// Render execute page by router's template engine with the default layout as
+// text/html response and mark the request as handled
+func (context *Context) Render(code int, name string, data interface{}) error {
+
+	if context.Router.templates == nil {
+		return context.Failed(nil, "router template engine not set")
+	}
+
+	return context.RenderLayout(code, context.Router.templates.layout, name, data)
+}
+
+// RenderLayout execute page with layout by router's template engine as
+// text/html response and mark the request as handled
+func (context *Context) RenderLayout(code int, layout string, name string, data interface{}) error {
+
+	engine := context.Router.templates
+
+	if engine == nil {
+		return context.Failed(nil, "router template engine not set")
+	}
+
+	var buff bytes.Buffer
+
+	if err := engine.RenderLayout(&buff, layout, name, data); err != nil {
+		return context.Failed(err, "render template %s error", name)
+	}
+
+	return context.write(code, "text/html; charset=utf-8", buff.Bytes())
+}
@@ -0,0 +1,313 @@
+package gsweb
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gsdocker/gserrors"
+	"github.com/gsdocker/gslogger"
+)
+
+// Span the timed operation of request processing, the root span covers the
+// whole request and every chain node entered through Forward has a child span
+type Span struct {
+	TraceID    string            `json:"traceId"`            // 32 hex digits trace id
+	SpanID     string            `json:"spanId"`             // 16 hex digits span id
+	ParentID   string            `json:"parentId,omitempty"` // parent span id, the remote parent for root span
+	Name       string            `json:"name"`               // span name
+	Start      time.Time         `json:"start"`              // span start time
+	Duration   time.Duration     `json:"duration"`           // span duration in nanoseconds
+	Error      string            `json:"error,omitempty"`    // the error returned by chain node
+	Attributes map[string]string `json:"attributes"`         // span attributes
+}
+
+// SpanExporter the exporter receiving the finished spans of a request
+type SpanExporter interface {
+	Export(spans []*Span) error
+}
+
+// Tracer the request tracer set by Router.SetTracer
+type Tracer struct {
+	gslogger.Log              // Mixin log APIs
+	exporter     SpanExporter // span exporter
+}
+
+// NewTracer create tracer exporting spans by exporter
+func NewTracer(exporter SpanExporter) *Tracer {
+	return &Tracer{
+		Log:      gslogger.Get("trace"),
+		exporter: exporter,
+	}
+}
+
+// requestTrace the trace state of request
+type requestTrace struct {
+	tracer  *Tracer // the tracer
+	sampled bool    // indicate if spans are exported
+	root    *Span   // the request span
+	current *Span   // the span of current chain node
+	spans   []*Span // the finished spans
+}
+
+// randomHex generate size random bytes encoded as hex
+func randomHex(size int) string {
+	buff := make([]byte, size)
+
+	if _, err := rand.Read(buff); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(buff)
+}
+
+// parseTraceparent parse W3C traceparent header, returns false if invalid
+func parseTraceparent(header string) (traceID string, parentID string, sampled bool, ok bool) {
+
+	parts := strings.Split(strings.TrimSpace(header), "-")
+
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return
+	}
+
+	// version 00 defines exactly four fields
+	if parts[0] == "00" && len(parts) != 4 {
+		return
+	}
+
+	flags, err := hex.DecodeString(parts[3])
+
+	if err != nil || !isHex(parts[0]) || !isHex(parts[1]) || !isHex(parts[2]) {
+		return
+	}
+
+	if strings.Trim(parts[1], "0") == "" || strings.Trim(parts[2], "0") == "" {
+		return
+	}
+
+	return parts[1], parts[2], flags[0]&1 == 1, true
+}
+
+func isHex(text string) bool {
+	return strings.Trim(text, "0123456789abcdef") == ""
+}
+
+func formatTraceparent(span *Span, sampled bool) string {
+	flags := "00"
+
+	if sampled {
+		flags = "01"
+	}
+
+	return "00-" + span.TraceID + "-" + span.SpanID + "-" + flags
+}
+
+// begin start request trace, the incoming traceparent becomes the remote parent
+func (tracer *Tracer) begin(context *Context) {
+
+	request := context.Request()
+
+	traceID, parentID, sampled, ok := parseTraceparent(request.Header.Get("traceparent"))
+
+	if !ok {
+		traceID, parentID, sampled = randomHex(16), "", true
+	}
+
+	root := &Span{
+		TraceID:  traceID,
+		SpanID:   randomHex(8),
+		ParentID: parentID,
+		Name:     request.Method + " " + request.URL.Path,
+		Start:    context.Started(),
+		Attributes: map[string]string{
+			"http.method": request.Method,
+			"http.target": request.RequestURI,
+			"http.host":   request.Host,
+			"request.id":  context.RequestID(),
+		},
+	}
+
+	context.trace = &requestTrace{tracer: tracer, sampled: sampled, root: root, current: root}
+
+	context.Response().Header().Set("traceparent", formatTraceparent(root, sampled))
+
+	if state := request.Header.Get("tracestate"); state != "" && ok {
+		context.Response().Header().Set("tracestate", state)
+	}
+}
+
+// end finish request span and export spans
+func (tracer *Tracer) end(context *Context) {
+
+	trace := context.trace
+
+	root := trace.root
+
+	root.Duration = time.Since(root.Start)
+	root.Attributes["http.status_code"] = strconv.Itoa(context.Status())
+
+	if route := context.Route(); route != "" {
+		root.Attributes["http.route"] = route
+	}
+
+	if context.failure != nil {
+		root.Error = context.failure.Error()
+	}
+
+	if !trace.sampled {
+		return
+	}
+
+	if err := tracer.exporter.Export(append(trace.spans, root)); err != nil {
+		tracer.E("export spans of trace %s error : %s", root.TraceID, err)
+	}
+}
+
+// call call chain node's method within span as child of current span
+func (trace *requestTrace) call(name string, context *Context, method MethodHandler) (err error) {
+
+	parent := trace.current
+
+	span := &Span{
+		TraceID:    parent.TraceID,
+		SpanID:     randomHex(8),
+		ParentID:   parent.SpanID,
+		Name:       name,
+		Start:      time.Now(),
+		Attributes: map[string]string{"gsweb.chain": name},
+	}
+
+	trace.current = span
+
+	defer func() {
+		span.Duration = time.Since(span.Start)
+
+		trace.current = parent
+		trace.spans = append(trace.spans, span)
+
+		if e := recover(); e != nil {
+			span.Error = fmt.Sprintf("panic : %v", e)
+			panic(e)
+		}
+
+		if err != nil {
+			span.Error = err.Error()
+		}
+	}()
+
+	return method(context)
+}
+
+// TraceID get request trace id, returns empty string if tracing disabled
+func (context *Context) TraceID() string {
+	if context.trace == nil {
+		return ""
+	}
+
+	return context.trace.root.TraceID
+}
+
+// Traceparent get the W3C traceparent header value of current span, set it
+// on outgoing requests to propagate the trace, returns empty string if
+// tracing disabled
+func (context *Context) Traceparent() string {
+	if context.trace == nil {
+		return ""
+	}
+
+	return formatTraceparent(context.trace.current, context.trace.sampled)
+}
+
+// FileSpanExporter the span exporter writing spans as JSON lines into file
+type FileSpanExporter struct {
+	mutex sync.Mutex // file guard
+	file  *os.File   // spans file
+}
+
+// NewFileSpanExporter create span exporter appending JSON lines to file path
+func NewFileSpanExporter(path string) (*FileSpanExporter, error) {
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+
+	if err != nil {
+		return nil, gserrors.Newf(err, "open spans file %s error", path)
+	}
+
+	return &FileSpanExporter{file: file}, nil
+}
+
+// Export implement SpanExporter
+func (exporter *FileSpanExporter) Export(spans []*Span) error {
+
+	var buff []byte
+
+	for _, span := range spans {
+		content, err := json.Marshal(span)
+
+		if err != nil {
+			return gserrors.Newf(err, "encode span %s error", span.SpanID)
+		}
+
+		buff = append(append(buff, content...), '\n')
+	}
+
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+
+	if _, err := exporter.file.Write(buff); err != nil {
+		return gserrors.Newf(err, "write spans error")
+	}
+
+	return nil
+}
+
+// Close close spans file
+func (exporter *FileSpanExporter) Close() error {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+
+	return exporter.file.Close()
+}
+
+// MemorySpanExporter the span exporter keeping spans in memory, used by tests
+type MemorySpanExporter struct {
+	mutex sync.Mutex // spans guard
+	spans []*Span    // exported spans
+}
+
+// NewMemorySpanExporter create in-memory span exporter
+func NewMemorySpanExporter() *MemorySpanExporter {
+	return &MemorySpanExporter{}
+}
+
+// Export implement SpanExporter
+func (exporter *MemorySpanExporter) Export(spans []*Span) error {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+
+	exporter.spans = append(exporter.spans, spans...)
+
+	return nil
+}
+
+// Spans get exported spans
+func (exporter *MemorySpanExporter) Spans() []*Span {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+
+	return append([]*Span(nil), exporter.spans...)
+}
+
+// Reset clear exported spans
+func (exporter *MemorySpanExporter) Reset() {
+	exporter.mutex.Lock()
+	defer exporter.mutex.Unlock()
+
+	exporter.spans = nil
+}
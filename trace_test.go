@@ -0,0 +1,93 @@
+package gsweb
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+
+	tests := []struct {
+		header  string
+		ok      bool
+		sampled bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", true, true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false, false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", false, false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7", false, false},
+		{"", false, false},
+	}
+
+	for _, test := range tests {
+		if _, _, sampled, ok := parseTraceparent(test.header); ok != test.ok || sampled != test.sampled {
+			t.Errorf("parse %q got %v %v, expect %v %v", test.header, ok, sampled, test.ok, test.sampled)
+		}
+	}
+}
+
+type traceTestHandler struct{}
+
+func (traceTestHandler) HandleGet(context *Context) error {
+	return context.Text(200, "ok")
+}
+
+func TestTraceSpans(t *testing.T) {
+
+	exporter := NewMemorySpanExporter()
+
+	router := newRouter()
+	router.SetTracer(NewTracer(exporter))
+
+	uri := NewURIHandler()
+	uri.Handle("/users/:id", traceTestHandler{})
+	router.ChainHandle("uri", uri)
+
+	request := httptest.NewRequest("GET", "/users/1", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	recorder := httptest.NewRecorder()
+
+	router.ServeHTTP(recorder, request)
+
+	spans := exporter.Spans()
+
+	if len(spans) != 2 {
+		t.Fatalf("expect 2 spans, got %d", len(spans))
+	}
+
+	node, root := spans[0], spans[1]
+
+	if root.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || root.ParentID != "00f067aa0ba902b7" {
+		t.Errorf("root span %+v not continue remote trace", root)
+	}
+
+	if node.ParentID != root.SpanID || node.Name != "uri" {
+		t.Errorf("chain node span %+v not child of root", node)
+	}
+
+	if root.Attributes["http.route"] != "/users/:id" || root.Attributes["http.status_code"] != "200" {
+		t.Errorf("root span attributes %v", root.Attributes)
+	}
+
+	if !strings.HasPrefix(recorder.Header().Get("traceparent"), "00-4bf92f3577b34da6a3ce929d0e0e4736-"+root.SpanID) {
+		t.Errorf("response traceparent %s", recorder.Header().Get("traceparent"))
+	}
+
+	exporter.Reset()
+
+	request = httptest.NewRequest("GET", "/users/1", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+
+	router.ServeHTTP(httptest.NewRecorder(), request)
+
+	if spans := exporter.Spans(); len(spans) != 0 {
+		t.Errorf("unsampled trace exported %d spans", len(spans))
+	}
+}
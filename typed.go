@@ -0,0 +1,85 @@
+package gsweb
+
+import (
+	"net/http"
+)
+
+// StatusCoder typed handler's response implement this interface choose the
+// response status code, default is 200 OK
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// Typed adapt typed handler function into MethodHandler.
+//
+// The adapter binds the request into Req: path parameters by path tag, query
+// parameters by query tag and request body by Context.Bind rules, then
+// validates it by the validate tag. The returned response is encoded by
+// Context.Negotiate unless the handler wrote response itself, the returned
+// error is mapped to status code by HTTPError.
+//
+//	uri.Handle("/users/:id", gsweb.Methods{
+//		"GET": gsweb.Typed(getUser),
+//	})
+func Typed[Req any, Resp any](handler func(context *Context, request Req) (Resp, error)) MethodHandler {
+	return func(context *Context) error {
+
+		var request Req
+
+		if err := bindRequest(context, &request); err != nil {
+			return err
+		}
+
+		response, err := handler(context, request)
+
+		if err != nil {
+			return err
+		}
+
+		if context.Handled() {
+			return context.Success()
+		}
+
+		code := http.StatusOK
+
+		if coder, ok := any(response).(StatusCoder); ok {
+			code = coder.StatusCode()
+		}
+
+		if code == http.StatusNoContent || code == http.StatusNotModified {
+			context.Response().WriteHeader(code)
+			return context.Success()
+		}
+
+		return context.Negotiate(code, response)
+	}
+}
+
+// bindRequest bind request body, path parameters and query parameters into v
+// then validate it, path and query parameters override body fields
+func bindRequest(context *Context, v interface{}) error {
+
+	request := context.Request()
+
+	if request.Method != "GET" && request.Method != "HEAD" && request.Method != "DELETE" {
+		if err := context.decodeBody(v); err != nil {
+			return err
+		}
+	}
+
+	params := make(map[string][]string, len(context.params))
+
+	for _, param := range context.params {
+		params[param.name] = []string{param.value}
+	}
+
+	if err := bindValues(v, params, "path"); err != nil {
+		return err
+	}
+
+	if err := bindValues(v, request.URL.Query(), "query"); err != nil {
+		return err
+	}
+
+	return Validate(v)
+}
@@ -0,0 +1,118 @@
+package gsweb
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type typedTestGetUser struct {
+	ID      int  `path:"id"`
+	Verbose bool `query:"verbose"`
+}
+
+type typedTestCreateUser struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type typedTestUser struct {
+	ID   int    `json:"id" xml:"id"`
+	Name string `json:"name" xml:"name"`
+}
+
+type typedTestCreated struct {
+	typedTestUser
+}
+
+func (typedTestCreated) StatusCode() int {
+	return http.StatusCreated
+}
+
+type typedTestDeleted struct{}
+
+func (typedTestDeleted) StatusCode() int {
+	return http.StatusNoContent
+}
+
+func TestTyped(t *testing.T) {
+
+	router := newRouter()
+
+	uri := NewURIHandler()
+	uri.Handle("/users/:id", Methods{
+		"GET": Typed(func(context *Context, request typedTestGetUser) (typedTestUser, error) {
+			if request.ID == 0 {
+				return typedTestUser{}, NewHTTPError(http.StatusNotFound, nil, "user %d not found", request.ID)
+			}
+
+			name := "user"
+
+			if request.Verbose {
+				name = "verbose user"
+			}
+
+			return typedTestUser{ID: request.ID, Name: name}, nil
+		}),
+		"DELETE": Typed(func(context *Context, request typedTestGetUser) (typedTestDeleted, error) {
+			return typedTestDeleted{}, nil
+		}),
+	})
+	uri.Handle("/users", Methods{
+		"POST": Typed(func(context *Context, request typedTestCreateUser) (typedTestCreated, error) {
+			return typedTestCreated{typedTestUser{ID: 1, Name: request.Name}}, nil
+		}),
+	})
+	uri.Handle("/raw", Methods{
+		"GET": Typed(func(context *Context, request struct{}) (*typedTestUser, error) {
+			return nil, context.Text(200, "raw")
+		}),
+	})
+	router.ChainHandle("uri", uri)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		accept string
+		body   string
+		code   int
+		expect string
+	}{
+		{"path parameter", "GET", "/users/7", "", "", 200, `{"id":7,"name":"user"}`},
+		{"query parameter", "GET", "/users/7?verbose=true", "", "", 200, `{"id":7,"name":"verbose user"}`},
+		{"negotiated xml", "GET", "/users/7", "application/xml", "", 200, `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<typedTestUser><id>7</id><name>user</name></typedTestUser>`},
+		{"invalid path parameter", "GET", "/users/x", "", "", 400, ""},
+		{"handler http error", "GET", "/users/0", "", "", 404, ""},
+		{"status coder", "POST", "/users", "", `{"name":"neo"}`, 201, `{"id":1,"name":"neo"}`},
+		{"validation error", "POST", "/users", "", `{}`, 400, ""},
+		{"invalid body", "POST", "/users", "", `{`, 400, ""},
+		{"no content", "DELETE", "/users/7", "", "", 204, ""},
+		{"handler wrote response", "GET", "/raw", "", "", 200, "raw"},
+	}
+
+	for _, test := range tests {
+
+		request := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+
+		if test.body != "" {
+			request.Header.Set("Content-Type", "application/json")
+		}
+
+		if test.accept != "" {
+			request.Header.Set("Accept", test.accept)
+		}
+
+		recorder := httptest.NewRecorder()
+
+		router.ServeHTTP(recorder, request)
+
+		if recorder.Code != test.code {
+			t.Errorf("%s code got %d, expect %d", test.name, recorder.Code, test.code)
+		}
+
+		if test.code < 400 && strings.TrimSpace(recorder.Body.String()) != test.expect {
+			t.Errorf("%s body got %q, expect %q", test.name, recorder.Body.String(), test.expect)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+package gsweb
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gsdocker/gserrors"
+)
+
+// routeParam the captured path parameter
+type routeParam struct {
+	name  string // parameter name
+	value string // captured value
+}
+
+// Route the uri route registered by URIHandler
+type Route struct {
+	pattern      string                   // the registered uri pattern
+	methods      map[string]MethodHandler // method handlers
+	operations   map[string]*Operation    // api documents indexed by method
+	explicitHead bool                     // HEAD handler registered explicitly, not replaced by GET
+}
+
+// Pattern get the registered uri pattern
+func (route *Route) Pattern() string {
+	return route.pattern
+}
+
+// Describe attach api document of method to route, used by OpenAPI generator
+func (route *Route) Describe(method string, operation Operation) *Route {
+	if route.operations == nil {
+		route.operations = make(map[string]*Operation)
+	}
+
+	route.operations[method] = &operation
+
+	return route
+}
+
+// paramNode the named segment edge, e.g. /users/:id or /users/:id(\d+)
+type paramNode struct {
+	name       string         // parameter name
+	constraint string         // regex constraint source, may be empty
+	regexp     *regexp.Regexp // compiled constraint
+	next       *uriNode       // the remaining path's node
+}
+
+// uriNode the radix tree node, static edges are prefix compressed
+type uriNode struct {
+	prefix   string       // static path fragment
+	children []*uriNode   // static children, first bytes are unique
+	params   []*paramNode // named segment children
+	catchAll *paramNode   // catch-all child, e.g. /files/*path
+	route    *Route       // the route terminated at this node
+}
+
+// uriToken the parsed pattern token
+type uriToken struct {
+	static     string // static fragment, empty for parameters
+	name       string // parameter name
+	constraint string // parameter regex constraint
+	catchAll   bool   // catch-all parameter flag
+}
+
+// indexParam get the index of the first ':' or '*' starting a path segment,
+// the ones inside segments are static, e.g. /v1/things:batchGet
+func indexParam(pattern string) int {
+	for i := 1; i < len(pattern); i++ {
+		if (pattern[i] == ':' || pattern[i] == '*') && pattern[i-1] == '/' {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func parseURIPattern(pattern string) []uriToken {
+
+	gserrors.Assert(strings.HasPrefix(pattern, "/"), "uri pattern %s must start with '/'", pattern)
+
+	var tokens []uriToken
+
+	for len(pattern) > 0 {
+
+		i := indexParam(pattern)
+
+		if i == -1 {
+			tokens = append(tokens, uriToken{static: pattern})
+			break
+		}
+
+		tokens = append(tokens, uriToken{static: pattern[:i]})
+
+		catchAll := pattern[i] == '*'
+
+		pattern = pattern[i+1:]
+
+		end := strings.IndexAny(pattern, "(/")
+
+		if end == -1 {
+			end = len(pattern)
+		}
+
+		token := uriToken{name: pattern[:end], catchAll: catchAll}
+
+		gserrors.Assert(token.name != "", "uri pattern parameter name can't be empty")
+
+		pattern = pattern[end:]
+
+		if strings.HasPrefix(pattern, "(") {
+			gserrors.Assert(!catchAll, "catch-all parameter %s can't have constraint", token.name)
+
+			depth := 0
+			end = -1
+
+			for j, c := range pattern {
+				if c == '(' {
+					depth++
+				} else if c == ')' {
+					depth--
+
+					if depth == 0 {
+						end = j
+						break
+					}
+				}
+			}
+
+			gserrors.Assert(end != -1, "parameter %s constraint missing ')'", token.name)
+
+			token.constraint = pattern[1:end]
+			pattern = pattern[end+1:]
+		}
+
+		gserrors.Assert(pattern == "" || pattern[0] == '/', "parameter %s must end with '/' or end of pattern", token.name)
+
+		gserrors.Assert(!catchAll || pattern == "", "catch-all parameter %s must be the last segment", token.name)
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens
+}
+
+func longestCommonPrefix(a, b string) int {
+	i := 0
+
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+
+	return i
+}
+
+// insert get the route of pattern, create it if not exists
+func (node *uriNode) insert(pattern string) *Route {
+
+	current := node
+
+	for _, token := range parseURIPattern(pattern) {
+
+		if token.static != "" {
+			current = current.insertStatic(token.static)
+			continue
+		}
+
+		if token.catchAll {
+			if current.catchAll == nil {
+				current.catchAll = &paramNode{name: token.name, next: &uriNode{}}
+			}
+
+			gserrors.Assert(
+				current.catchAll.name == token.name,
+				"catch-all parameter %s conflict with %s in %s", token.name, current.catchAll.name, pattern)
+
+			current = current.catchAll.next
+			continue
+		}
+
+		current = current.insertParam(pattern, token)
+	}
+
+	if current.route == nil {
+		current.route = &Route{pattern: pattern, methods: make(map[string]MethodHandler)}
+	}
+
+	return current.route
+}
+
+func (node *uriNode) insertStatic(path string) *uriNode {
+
+	if path == "" {
+		return node
+	}
+
+	for _, child := range node.children {
+
+		length := longestCommonPrefix(child.prefix, path)
+
+		if length == 0 {
+			continue
+		}
+
+		if length < len(child.prefix) {
+			// split the child node by common prefix
+			split := *child
+			split.prefix = child.prefix[length:]
+
+			*child = uriNode{
+				prefix:   child.prefix[:length],
+				children: []*uriNode{&split},
+			}
+		}
+
+		return child.insertStatic(path[length:])
+	}
+
+	child := &uriNode{prefix: path}
+
+	node.children = append(node.children, child)
+
+	return child
+}
+
+func (node *uriNode) insertParam(pattern string, token uriToken) *uriNode {
+
+	for _, param := range node.params {
+		if param.constraint == token.constraint {
+
+			gserrors.Assert(
+				param.name == token.name,
+				"parameter %s conflict with %s in %s", token.name, param.name, pattern)
+
+			return param.next
+		}
+	}
+
+	param := &paramNode{
+		name:       token.name,
+		constraint: token.constraint,
+		next:       &uriNode{},
+	}
+
+	if token.constraint != "" {
+		var err error
+		param.regexp, err = regexp.Compile("^(?:" + token.constraint + ")$")
+		gserrors.Assert(err == nil, "parameter %s invalid constraint %s : %s", token.name, token.constraint, err)
+
+		// constrained parameters take precedence over unconstrained one
+		node.params = append([]*paramNode{param}, node.params...)
+	} else {
+		node.params = append(node.params, param)
+	}
+
+	return param.next
+}
+
+// lookup search route by request path, the captured parameters are appended to params
+func (node *uriNode) lookup(path string, params *[]routeParam) *Route {
+
+	if path == "" && node.route != nil {
+		return node.route
+	}
+
+	for _, child := range node.children {
+		if strings.HasPrefix(path, child.prefix) {
+			if found := child.lookup(path[len(child.prefix):], params); found != nil {
+				return found
+			}
+
+			break
+		}
+	}
+
+	if len(node.params) > 0 {
+
+		end := strings.IndexByte(path, '/')
+
+		if end == -1 {
+			end = len(path)
+		}
+
+		if segment := path[:end]; segment != "" {
+
+			for _, param := range node.params {
+
+				if param.regexp != nil && !param.regexp.MatchString(segment) {
+					continue
+				}
+
+				*params = append(*params, routeParam{name: param.name, value: segment})
+
+				if found := param.next.lookup(path[end:], params); found != nil {
+					return found
+				}
+
+				*params = (*params)[:len(*params)-1]
+			}
+		}
+	}
+
+	if node.catchAll != nil && node.catchAll.next.route != nil {
+		*params = append(*params, routeParam{name: node.catchAll.name, value: path})
+		return node.catchAll.next.route
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package gsweb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestURITreeLookup(t *testing.T) {
+
+	root := &uriNode{}
+
+	for _, pattern := range []string{
+		"/",
+		"/users",
+		"/users/:id",
+		`/users/:id(\d+)/posts`,
+		"/users/:id/profile",
+		"/users/me",
+		"/files/*path",
+		"/v1/things:batchGet",
+	} {
+		root.insert(pattern)
+	}
+
+	tests := []struct {
+		path    string
+		pattern string
+		params  string
+	}{
+		{"/", "/", ""},
+		{"/users", "/users", ""},
+		{"/users/me", "/users/me", ""},
+		{"/users/42", "/users/:id", "id=42"},
+		{"/users/42/posts", `/users/:id(\d+)/posts`, "id=42"},
+		{"/users/bob/posts", "", ""},
+		{"/users/bob/profile", "/users/:id/profile", "id=bob"},
+		{"/files/a/b.txt", "/files/*path", "path=a/b.txt"},
+		{"/v1/things:batchGet", "/v1/things:batchGet", ""},
+		{"/v1/things:batch", "", ""},
+		{"/missing", "", ""},
+	}
+
+	for _, test := range tests {
+
+		var params []routeParam
+
+		route := root.lookup(test.path, &params)
+
+		pattern := ""
+
+		if route != nil {
+			pattern = route.Pattern()
+		}
+
+		var captured []string
+
+		for _, param := range params {
+			captured = append(captured, param.name+"="+param.value)
+		}
+
+		if pattern != test.pattern || strings.Join(captured, ",") != test.params {
+			t.Errorf("lookup %s got %q %v, expect %q %s", test.path, pattern, captured, test.pattern, test.params)
+		}
+	}
+}
+
+func TestURITreeInvalidPattern(t *testing.T) {
+
+	for _, pattern := range []string{
+		"users",
+		"/users/:",
+		"/files/*path/more",
+		`/users/:id(\d+`,
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("pattern %s expect panic", pattern)
+				}
+			}()
+
+			(&uriNode{}).insert(pattern)
+		}()
+	}
+}
@@ -4,15 +4,15 @@ import "github.com/gsdocker/gslogger"
 
 // URIHandler .
 type URIHandler struct {
-	gslogger.Log                                     // Mixin log APIs
-	handlers     map[string]map[string]MethodHandler // uri handlers
+	gslogger.Log          // Mixin log APIs
+	tree         *uriNode // uri handlers radix tree
 }
 
 // NewURIHandler create new URIHandler
 func NewURIHandler() *URIHandler {
 	return &URIHandler{
-		Log:      gslogger.Get("URI"),
-		handlers: make(map[string]map[string]MethodHandler),
+		Log:  gslogger.Get("URI"),
+		tree: &uriNode{},
 	}
 }
 
@@ -24,10 +24,25 @@ func (uri *URIHandler) HandleUnknown(context *Context) error {
 
 	uri.V("%s %s forward processing", requestMethod, requestURI)
 
-	if handler, ok := uri.handlers[requestURI]; ok {
-		if method, ok := handler[requestMethod]; ok {
+	var params []routeParam
 
-			uri.D("%s %s handler -- found", requestMethod, requestURI)
+	if route := uri.tree.lookup(requestURI, &params); route != nil {
+		method, ok := route.methods[requestMethod]
+
+		if !ok {
+			method, ok = route.methods["UNKNOWN"]
+		}
+
+		if !ok && requestMethod == "OPTIONS" {
+			method, ok = optionsHandler(allowedMethods(route.methods)), true
+		}
+
+		if ok {
+
+			uri.D("%s %s handler(%s) -- found", requestMethod, requestURI, route.pattern)
+
+			context.params = params
+			context.route = route.pattern
 
 			if err := method(context); err != nil {
 				uri.E("%s %s handler execute error : %s ", requestMethod, requestURI, err)
@@ -35,6 +50,10 @@ func (uri *URIHandler) HandleUnknown(context *Context) error {
 			}
 
 			uri.D("%s %s handler execute -- success ", requestMethod, requestURI)
+		} else {
+			uri.D("%s %s handler(%s) -- method not allowed", requestMethod, requestURI, route.pattern)
+
+			context.allowMethods = mergeMethods(context.allowMethods, allowedMethods(route.methods))
 		}
 	}
 
@@ -45,7 +64,54 @@ func (uri *URIHandler) HandleUnknown(context *Context) error {
 	return err
 }
 
-// Handle register uri handler
-func (uri *URIHandler) Handle(requestURI string, handler interface{}) {
-	uri.handlers[requestURI] = ExtractMethods(handler)
+// Handle register uri handler, the requestURI may contain named segments
+// (/users/:id), named segments with regex constraint (/users/:id(\d+)) and
+// trailing catch-all segment (/files/*path)
+func (uri *URIHandler) Handle(requestURI string, handler interface{}) *Route {
+	route := uri.tree.insert(requestURI)
+
+	route.methods = ExtractMethods(handler)
+	route.explicitHead = explicitHead(handler)
+
+	return route
+}
+
+// explicitHead check if handler serves HEAD itself
+func explicitHead(handler interface{}) bool {
+	if methods, ok := handler.(Methods); ok {
+		if _, ok := methods["HEAD"]; ok {
+			return true
+		}
+	}
+
+	_, ok := handler.(Head)
+
+	return ok
+}
+
+// HandleMethod register uri handler for single method, other methods
+// registered on the same requestURI are kept, registering GET also serves HEAD
+// unless a HEAD handler was registered explicitly
+func (uri *URIHandler) HandleMethod(method string, requestURI string, handler MethodHandler) *Route {
+	route := uri.tree.insert(requestURI)
+
+	methods := make(map[string]MethodHandler, len(route.methods)+1)
+
+	for name, h := range route.methods {
+		methods[name] = h
+	}
+
+	methods[method] = handler
+
+	if method == "HEAD" {
+		route.explicitHead = true
+	}
+
+	if method == "GET" && !route.explicitHead {
+		methods["HEAD"] = headHandler(handler)
+	}
+
+	route.methods = methods
+
+	return route
 }
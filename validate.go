@@ -0,0 +1,271 @@
+package gsweb
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/gsdocker/gserrors"
+)
+
+var validateRegexps sync.Map // the compiled regex rules cache
+
+// Validate validate struct pointed by v by the fields' validate tag, returns
+// *HTTPError responds 400 Bad Request listing every invalid field.
+//
+// The validate tag is comma separated rules:
+//
+//	required   the field must not be zero value
+//	min=N      number value >= N, or string/slice/map length >= N
+//	max=N      number value <= N, or string/slice/map length <= N
+//	enum=a|b   the field value (each element for slice) must be one of the list
+//	regex=expr string field must match expr, must be the last rule
+//
+// Rules except required are skipped for absent optional fields: nil pointers,
+// nil slices and maps, and empty strings, numeric zero is still checked. Nested
+// structs are validated recursively and named as parent.child
+func Validate(v interface{}) error {
+
+	value, ok := structValue(v)
+
+	if !ok {
+		return nil
+	}
+
+	var fields []FieldError
+
+	validateStruct(value, "", &fields)
+
+	if len(fields) > 0 {
+		return &HTTPError{
+			Code:    http.StatusBadRequest,
+			Message: "invalid request parameters",
+			Fields:  fields,
+		}
+	}
+
+	return nil
+}
+
+// validateFieldName get field's public name, prefer json tag, then form tag
+func validateFieldName(field reflect.StructField) string {
+	for _, tag := range []string{"json", "xml", "form", "query", "path"} {
+		if name := strings.Split(field.Tag.Get(tag), ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+
+	return field.Name
+}
+
+func validateStruct(value reflect.Value, prefix string, fields *[]FieldError) {
+
+	valueType := value.Type()
+
+	for i := 0; i < valueType.NumField(); i++ {
+
+		field := valueType.Field(i)
+
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		fieldValue := value.Field(i)
+
+		name := prefix + validateFieldName(field)
+
+		if field.Anonymous && field.Tag.Get("json") == "" {
+			name = strings.TrimSuffix(prefix, ".")
+		}
+
+		if rules := field.Tag.Get("validate"); rules != "" && rules != "-" {
+			if reason := validateField(fieldValue, rules); reason != "" {
+				*fields = append(*fields, FieldError{Field: name, Reason: reason})
+				continue
+			}
+		}
+
+		for fieldValue.Kind() == reflect.Ptr && !fieldValue.IsNil() {
+			fieldValue = fieldValue.Elem()
+		}
+
+		if fieldValue.Kind() == reflect.Struct && fieldValue.Type().NumField() > 0 && !isOpaqueStruct(fieldValue.Type()) {
+			if field.Anonymous {
+				validateStruct(fieldValue, prefix, fields)
+			} else {
+				validateStruct(fieldValue, name+".", fields)
+			}
+		}
+	}
+}
+
+// isOpaqueStruct check if the struct type is value object not validated recursively, e.g. time.Time
+func isOpaqueStruct(structType reflect.Type) bool {
+	return reflect.PointerTo(structType).Implements(textUnmarshalerType)
+}
+
+// splitRules split validate tag, the regex rule consumes the rest of tag
+func splitRules(rules string) []string {
+	var result []string
+
+	for rules != "" {
+		if strings.HasPrefix(rules, "regex=") {
+			result = append(result, rules)
+			break
+		}
+
+		i := strings.IndexByte(rules, ',')
+
+		if i == -1 {
+			result = append(result, rules)
+			break
+		}
+
+		result = append(result, rules[:i])
+		rules = rules[i+1:]
+	}
+
+	return result
+}
+
+// validateField validate field value, returns the invalid reason or empty string
+func validateField(value reflect.Value, rules string) string {
+
+	for _, rule := range splitRules(rules) {
+		if rule == "required" && value.IsZero() {
+			return "required"
+		}
+	}
+
+	// absent optional values are valid, numeric zero is still checked
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return ""
+		}
+
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		if value.Len() == 0 {
+			return ""
+		}
+	case reflect.Slice, reflect.Map, reflect.Interface:
+		if value.IsNil() {
+			return ""
+		}
+	}
+
+	for _, rule := range splitRules(rules) {
+
+		name, arg := rule, ""
+
+		if i := strings.IndexByte(rule, '='); i != -1 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+
+		switch name {
+		case "required":
+
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+
+			gserrors.Assert(err == nil, "invalid validate rule %s : %s", rule, err)
+
+			measure, isLength := measureValue(value)
+
+			if (name == "min" && measure < limit) || (name == "max" && measure > limit) {
+				if isLength {
+					return fmt.Sprintf("length must be %s %s", boundWord(name), arg)
+				}
+
+				return fmt.Sprintf("must be %s %s", boundWord(name), arg)
+			}
+
+		case "enum":
+			options := strings.Split(arg, "|")
+
+			if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+				for i := 0; i < value.Len(); i++ {
+					if !inOptions(value.Index(i), options) {
+						return fmt.Sprintf("must be one of %s", strings.Join(options, ", "))
+					}
+				}
+			} else if !inOptions(value, options) {
+				return fmt.Sprintf("must be one of %s", strings.Join(options, ", "))
+			}
+
+		case "regex":
+			if value.Kind() == reflect.String && !validateRegexp(arg).MatchString(value.String()) {
+				return fmt.Sprintf("must match %s", arg)
+			}
+
+		default:
+			gserrors.Assert(false, "unknown validate rule %s", rule)
+		}
+	}
+
+	return ""
+}
+
+func boundWord(name string) string {
+	if name == "min" {
+		return "at least"
+	}
+
+	return "at most"
+}
+
+// measureValue get the value compared by min/max rules, the second return
+// value indicate if it's the length of value
+func measureValue(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), false
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), false
+	case reflect.String:
+		return float64(utf8.RuneCountInString(value.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len()), true
+	}
+
+	gserrors.Assert(false, "min/max rule not support type %s", value.Type())
+
+	return 0, false
+}
+
+func inOptions(value reflect.Value, options []string) bool {
+	text := fmt.Sprint(value.Interface())
+
+	for _, option := range options {
+		if option == text {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateRegexp(expr string) *regexp.Regexp {
+
+	if cached, ok := validateRegexps.Load(expr); ok {
+		return cached.(*regexp.Regexp)
+	}
+
+	compiled, err := regexp.Compile(expr)
+
+	gserrors.Assert(err == nil, "invalid validate regex %s : %s", expr, err)
+
+	validateRegexps.Store(expr, compiled)
+
+	return compiled
+}
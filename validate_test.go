@@ -0,0 +1,69 @@
+package gsweb
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+
+	type address struct {
+		City string `json:"city" validate:"required"`
+	}
+
+	type order struct {
+		Name     string   `json:"name" validate:"required,max=5"`
+		Qty      int      `json:"qty" validate:"min=1,max=10"`
+		Price    float64  `json:"price" validate:"min=0.01"`
+		Discount *int     `json:"discount" validate:"max=50"`
+		Status   string   `json:"status" validate:"enum=open|closed"`
+		Tags     []string `json:"tags" validate:"max=2,enum=a|b|c"`
+		Code     string   `json:"code" validate:"regex=^[A-Z]{2}\\d+$"`
+		Address  address  `json:"address"`
+	}
+
+	zero, sixty := 0, 60
+
+	valid := order{Name: "pen", Qty: 1, Price: 0.5, Address: address{City: "x"}}
+
+	tests := []struct {
+		name    string
+		modify  func(o *order)
+		invalid string
+	}{
+		{"valid", func(o *order) {}, ""},
+		{"optional absent", func(o *order) { o.Status, o.Tags, o.Code, o.Discount = "", nil, "", nil }, ""},
+		{"required", func(o *order) { o.Name = "" }, "name:required"},
+		{"max length", func(o *order) { o.Name = "pencil" }, "name:length must be at most 5"},
+		{"zero int", func(o *order) { o.Qty = 0 }, "qty:must be at least 1"},
+		{"zero float", func(o *order) { o.Price = 0 }, "price:must be at least 0.01"},
+		{"zero pointer", func(o *order) { o.Discount = &zero }, ""},
+		{"pointer max", func(o *order) { o.Discount = &sixty }, "discount:must be at most 50"},
+		{"enum", func(o *order) { o.Status = "pending" }, "status:must be one of open, closed"},
+		{"slice enum", func(o *order) { o.Tags = []string{"a", "d"} }, "tags:must be one of a, b, c"},
+		{"slice max", func(o *order) { o.Tags = []string{"a", "b", "c"} }, "tags:length must be at most 2"},
+		{"regex", func(o *order) { o.Code = "ab1" }, "code:must match ^[A-Z]{2}\\d+$"},
+		{"nested", func(o *order) { o.Address.City = "" }, "address.city:required"},
+	}
+
+	for _, test := range tests {
+
+		o := valid
+
+		test.modify(&o)
+
+		err := Validate(&o)
+
+		var invalid []string
+
+		if err != nil {
+			for _, field := range err.(*HTTPError).Fields {
+				invalid = append(invalid, field.Field+":"+field.Reason)
+			}
+		}
+
+		if strings.Join(invalid, ";") != test.invalid {
+			t.Errorf("%s got %v, expect %s", test.name, invalid, test.invalid)
+		}
+	}
+}
@@ -1,35 +1,125 @@
 package gsweb
 
 import (
+	"context"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/gsdocker/gsconfig"
 	"github.com/gsdocker/gslogger"
 )
 
+// StartHook chain handlers implement this interface will be notified before
+// the website start serving
+type StartHook interface {
+	OnStart(website *WebSite) error
+}
+
+// ShutdownHook chain handlers implement this interface will be notified after
+// the website drained in-flight requests, before the process exits
+type ShutdownHook interface {
+	OnShutdown(ctx context.Context) error
+}
+
 // WebSite The website object
 type WebSite struct {
-	gslogger.Log // Mixin log apis
-	*Router      // Minx Router
+	gslogger.Log                                    // Mixin log apis
+	*Router                                         // Minx Router
+	mutex         sync.Mutex                        // servers guard
+	drainTimeout  time.Duration                     // max time waiting for in-flight requests when receive signal
+	hookTimeout   time.Duration                     // max time of the shutdown hooks, independent of drain timeout
+	handleSignals bool                              // handle SIGINT/SIGTERM flag
+	servers       map[*http.Server]bool             // running servers
+	onStart       []func(website *WebSite) error    // start hooks
+	onShutdown    []func(ctx context.Context) error // shutdown hooks
+	startOnce     sync.Once                         // start hooks guard
+	signalOnce    sync.Once                         // signal handler guard
+	shutdownOnce  sync.Once                         // shutdown guard
+	shutdown      chan struct{}                     // closed when shutdown started
+	stopped       chan struct{}                     // closed when shutdown completed
+	shutdownErr   error                             // shutdown result
+	baseCtx       context.Context                   // the requests' base context
+	cancelBase    context.CancelFunc                // cancel the requests' base context
 }
 
 // NewWebSite create new gsweb instance
 func NewWebSite() *WebSite {
+
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+
 	return &WebSite{
-		Log:    gslogger.Get("gsweb"),
-		Router: newRouter(),
+		Log:           gslogger.Get("gsweb"),
+		Router:        newRouter(),
+		drainTimeout:  gsconfig.Seconds("drain_timeout", 30),
+		hookTimeout:   gsconfig.Seconds("shutdown_hook_timeout", 10),
+		handleSignals: true,
+		servers:       make(map[*http.Server]bool),
+		shutdown:      make(chan struct{}),
+		stopped:       make(chan struct{}),
+		baseCtx:       baseCtx,
+		cancelBase:    cancelBase,
 	}
 
 }
 
-// RunHTTP start listen in connection and run dispatch loop
+// SetDrainTimeout set the max time waiting for in-flight requests when
+// shutdown is triggered by SIGINT/SIGTERM
+func (website *WebSite) SetDrainTimeout(timeout time.Duration) {
+	website.drainTimeout = timeout
+}
+
+// SetShutdownHookTimeout set the max time of the shutdown hooks, the hooks get
+// their own context so they can still clean up after draining timed out
+func (website *WebSite) SetShutdownHookTimeout(timeout time.Duration) {
+	website.hookTimeout = timeout
+}
+
+// HandleSignals set flag, true enable shutdown website on SIGINT/SIGTERM,
+// otherwise the caller is responsible for calling Shutdown
+func (website *WebSite) HandleSignals(flag bool) {
+	website.handleSignals = flag
+}
+
+// OnStart register hook called once before the website start serving
+func (website *WebSite) OnStart(hook func(website *WebSite) error) {
+	website.onStart = append(website.onStart, hook)
+}
+
+// OnShutdown register hook called after the website drained in-flight requests,
+// hooks are called in reverse register order with the shutdown hook timeout context
+func (website *WebSite) OnShutdown(hook func(ctx context.Context) error) {
+	website.onShutdown = append(website.onShutdown, hook)
+}
+
+// RunHTTP start listen in connection and run dispatch loop,
+// returns after the website shutdown
 func (website *WebSite) RunHTTP(laddr string) {
+	website.run("http", laddr, func(server *http.Server) error {
+		return server.ListenAndServe()
+	})
+}
+
+// RunHTTPS start listen in connection and run dispatch loop,
+// returns after the website shutdown
+func (website *WebSite) RunHTTPS(laddr string, certfile string, keyfile string) {
+	website.run("https", laddr, func(server *http.Server) error {
+		return server.ListenAndServeTLS(certfile, keyfile)
+	})
+}
+
+func (website *WebSite) run(protocol string, laddr string, listen func(server *http.Server) error) {
+
+	website.startOnce.Do(website.start)
 
 	for {
-		website.D("start http server : %s", laddr)
+		website.D("start %s server : %s", protocol, laddr)
 
-		server := http.Server{
+		server := &http.Server{
 			Addr:           laddr,
 			ReadTimeout:    gsconfig.Seconds("read_timeout", 10),
 			WriteTimeout:   gsconfig.Seconds("writet_imeout", 10),
@@ -38,49 +128,171 @@ func (website *WebSite) RunHTTP(laddr string) {
 
 		server.Handler = website
 
-		err := server.ListenAndServe()
+		server.BaseContext = func(net.Listener) context.Context {
+			return website.baseCtx
+		}
 
-		if err != nil {
-			website.E("start http err :%s", err)
+		if !website.track(server) {
+			break
+		}
 
-			timeout := gsconfig.Seconds("retry_timeout", 5)
+		err := listen(server)
 
-			website.E("retry start http server %v later", timeout)
+		website.untrack(server)
 
-			<-time.After(timeout)
+		if err == http.ErrServerClosed {
+			break
 		}
 
+		website.E("start %s err :%s", protocol, err)
+
+		timeout := gsconfig.Seconds("retry_timeout", 5)
+
+		website.E("retry start %s server %v later", protocol, timeout)
+
+		select {
+		case <-time.After(timeout):
+		case <-website.shutdown:
+		}
 	}
 
+	<-website.stopped
+
+	website.D("%s server %s stopped", protocol, laddr)
 }
 
-// RunHTTPS start listen in connection and run dispatch loop
-func (website *WebSite) RunHTTPS(laddr string, certfile string, keyfile string) {
+func (website *WebSite) start() {
 
-	for {
-		website.D("start https server : %s", laddr)
+	if website.handleSignals {
+		website.signalOnce.Do(website.watchSignals)
+	}
 
-		server := http.Server{
-			Addr:           laddr,
-			ReadTimeout:    gsconfig.Seconds("read_timeout", 10),
-			WriteTimeout:   gsconfig.Seconds("writet_imeout", 10),
-			MaxHeaderBytes: gsconfig.Int("max_header_bytes", 1<<20),
+	for _, hook := range website.onStart {
+		if err := hook(website); err != nil {
+			website.E("call website start hook error :%s", err)
+		}
+	}
+
+	for _, handler := range website.chain() {
+		if hook, ok := handler.target.(StartHook); ok {
+			if err := hook.OnStart(website); err != nil {
+				website.E("call handler %s start hook error :%s", handler.name, err)
+			}
 		}
+	}
+}
 
-		server.Handler = website
+func (website *WebSite) watchSignals() {
 
-		err := server.ListenAndServeTLS(certfile, keyfile)
+	signals := make(chan os.Signal, 1)
 
-		if err != nil {
-			website.E("start https err :%s", err)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
-			timeout := gsconfig.Seconds("retry_timeout", 5)
+	go func() {
+		defer signal.Stop(signals)
 
-			website.E("retry start https server %v later", timeout)
+		select {
+		case sig := <-signals:
+			website.I("receive signal %s, shutdown website(drain timeout %v) ...", sig, website.drainTimeout)
 
-			<-time.After(timeout)
+			ctx, cancel := context.WithTimeout(context.Background(), website.drainTimeout)
+			defer cancel()
+
+			if err := website.Shutdown(ctx); err != nil {
+				website.E("shutdown website error :%s", err)
+			}
+
+		case <-website.shutdown:
 		}
+	}()
+}
 
+func (website *WebSite) track(server *http.Server) bool {
+	website.mutex.Lock()
+	defer website.mutex.Unlock()
+
+	select {
+	case <-website.shutdown:
+		return false
+	default:
 	}
 
+	website.servers[server] = true
+
+	return true
+}
+
+func (website *WebSite) untrack(server *http.Server) {
+	website.mutex.Lock()
+	defer website.mutex.Unlock()
+
+	delete(website.servers, server)
+}
+
+// Shutdown gracefully shutdown all running servers: stop accepting new
+// connections, wait for in-flight requests until ctx is done, then call the
+// shutdown hooks with a fresh context bounded by the shutdown hook timeout.
+// Calling Shutdown more than once returns the first result
+func (website *WebSite) Shutdown(ctx context.Context) error {
+
+	website.shutdownOnce.Do(func() {
+
+		website.mutex.Lock()
+
+		close(website.shutdown)
+
+		servers := make([]*http.Server, 0, len(website.servers))
+
+		for server := range website.servers {
+			servers = append(servers, server)
+		}
+
+		website.mutex.Unlock()
+
+		for _, server := range servers {
+			if err := server.Shutdown(ctx); err != nil {
+				website.E("shutdown server %s error :%s", server.Addr, err)
+
+				if website.shutdownErr == nil {
+					website.shutdownErr = err
+				}
+			}
+		}
+
+		// abort requests still running after drain timeout
+		website.cancelBase()
+
+		// the drain context may already be expired, hooks get their own
+		hookCtx, cancel := context.WithTimeout(context.Background(), website.hookTimeout)
+
+		defer cancel()
+
+		handleChain := website.chain()
+
+		for i := len(handleChain) - 1; i >= 0; i-- {
+			if hook, ok := handleChain[i].target.(ShutdownHook); ok {
+				if err := hook.OnShutdown(hookCtx); err != nil {
+					website.E("call handler %s shutdown hook error :%s", handleChain[i].name, err)
+
+					if website.shutdownErr == nil {
+						website.shutdownErr = err
+					}
+				}
+			}
+		}
+
+		for i := len(website.onShutdown) - 1; i >= 0; i-- {
+			if err := website.onShutdown[i](hookCtx); err != nil {
+				website.E("call website shutdown hook error :%s", err)
+
+				if website.shutdownErr == nil {
+					website.shutdownErr = err
+				}
+			}
+		}
+
+		close(website.stopped)
+	})
+
+	return website.shutdownErr
 }